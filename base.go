@@ -15,15 +15,81 @@ limitations under the License.
 */
 package version
 
+// defaultGitCommit is the value of the git commit for builds without version information.
+const defaultGitCommit = "$Format:%H$"
+
 // Version value defaults
 var (
 	// Version string, a slightly modified version of `git describe` to be semver-complaint
 	version      string = "v0.0.0-master+$Format:%h$"
-	gitCommit    string = "$Format:%H$"    // sha1 from git, output of $(git rev-parse HEAD)
+	gitCommit    string = defaultGitCommit // sha1 from git, output of $(git rev-parse HEAD)
 	gitTreeState string = "not a git tree" // state of git tree, either "clean" or "dirty"
+	gitTreeDirty string                    // "true" if the git tree is dirty, empty if the state is only given with gitTreeState
+	gitBranch    string                    // branch the build was made from, empty for a detached HEAD
+	goOS         string                    // target operating system
+	goArch       string                    // target architecture
+	platform     string                    // target platform as os/arch, an alternative to goOS and goArch
+	// sha1 of the source tree object, output of $(git rev-parse HEAD^{tree})
+	sourceTreeHash string
+	// commit time in strict ISO 8601 format, output of $(git log -1 --format=%cI HEAD)
+	commitTime string
+	// Build time in RFC 3339 format and the source it has been obtained from
+	buildTime       string
+	buildTimeSource string
+	// User and host the build has been made by
+	buildUser string
+	buildHost string
+	// JSON-encoded list of submodules
+	gitSubmodules string
+	// product edition, e.g. "community" or "enterprise"
+	edition string
+	// path of the main module, output of $(go list -m)
+	modulePath string
+	// JSON-encoded map of custom build attributes
+	buildAttrs string
+	// Individual components of the version, only set if the linker flags were generated with `-split-version`
+	versionMajor string
+	versionMinor string
+	versionPatch string
 )
 
 // Init sets an alternative default for the version string.
 func Init(baseVersion string) {
 	version = baseVersion
 }
+
+// Set overrides the build version information with info.
+// It is intended for tests that need to simulate a particular build.
+func Set(info Info) {
+	version = info.Version
+	gitCommit = info.GitCommit
+	gitTreeState = info.GitTreeState
+	gitTreeDirty = ""
+	if info.Dirty {
+		gitTreeDirty = "true"
+	}
+	gitBranch = info.GitBranch
+	goOS = info.GoOS
+	goArch = info.GoArch
+	platform = info.BuildPlatform
+	sourceTreeHash = info.SourceTreeHash
+	commitTime = info.CommitTime
+	buildTime = info.BuildTime
+	buildTimeSource = info.BuildTimeSource
+	buildUser = info.BuildUser
+	buildHost = info.BuildHost
+	gitSubmodules = encodeSubmodules(info.Submodules)
+	edition = info.Edition
+	modulePath = info.ModulePath
+	buildAttrs = encodeAttrs(info.Attrs)
+	versionMajor = info.VersionMajor
+	versionMinor = info.VersionMinor
+	versionPatch = info.VersionPatch
+}
+
+// Minimal returns the version of the build without computing the complete version information.
+// It is meant for size-constrained binaries built with `linkflags -minimal` that only carry the version.
+// Without the commit ID such builds are development builds for IsDevBuild and Info.Channel.
+func Minimal() string {
+	return decodeValue(version)
+}
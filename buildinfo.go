@@ -0,0 +1,67 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import "runtime/debug"
+
+// Best returns the most complete version information available: the information injected
+// with the linker flags completed with the build information embedded by the Go toolchain
+// (see debug.ReadBuildInfo). Injected fields always take precedence, the build information
+// only fills the gaps, e.g. the commit of a build that was only injected the version.
+func Best() Info {
+	info := Get()
+	buildInfo, ok := readBuildInfo()
+	if !ok {
+		return info
+	}
+	return mergeBuildInfo(info, buildInfo)
+}
+
+// mergeBuildInfo fills the fields of info that have not been injected
+// with the values from buildInfo.
+func mergeBuildInfo(info Info, buildInfo *debug.BuildInfo) Info {
+	settings := make(map[string]string)
+	for _, setting := range buildInfo.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if info.unversioned() {
+		if version := buildInfo.Main.Version; version != "" && version != "(devel)" {
+			info.Version = version
+		}
+	}
+	if info.GitCommit == "" || placeholder(info.GitCommit) {
+		if revision := settings["vcs.revision"]; revision != "" {
+			info.GitCommit = revision
+		}
+	}
+	if info.GitTreeState != treeStateClean && info.GitTreeState != treeStateDirty {
+		switch settings["vcs.modified"] {
+		case "true":
+			info.GitTreeState = treeStateDirty
+			info.Dirty = true
+		case "false":
+			info.GitTreeState = treeStateClean
+			info.Dirty = false
+		}
+	}
+	if info.CommitTime == "" {
+		info.CommitTime = settings["vcs.time"]
+	}
+	if info.GoOS == "" && info.GoArch == "" && info.BuildPlatform == "" {
+		info.GoOS, info.GoArch = settings["GOOS"], settings["GOARCH"]
+	}
+	return info
+}
@@ -0,0 +1,100 @@
+package version
+
+import (
+	"reflect"
+	"runtime/debug"
+	"testing"
+)
+
+func TestBest(t *testing.T) {
+	defer saveVars()()
+	defer func(read func() (*debug.BuildInfo, bool)) { readBuildInfo = read }(readBuildInfo)
+
+	const (
+		commitID      = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+		otherCommitID = "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"
+	)
+	buildInfo := &debug.BuildInfo{
+		Main: debug.Module{Path: "github.com/example/app", Version: "v1.3.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "GOOS", Value: "linux"},
+			{Key: "GOARCH", Value: "arm64"},
+			{Key: "vcs.revision", Value: otherCommitID},
+			{Key: "vcs.time", Value: "2024-06-01T10:30:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return buildInfo, true }
+
+	var testCases = []struct {
+		comment  string
+		injected Info
+		expected Info
+	}{
+		{
+			comment:  "nothing injected",
+			injected: Info{},
+			expected: Info{
+				Version:      "v1.3.0",
+				GitCommit:    otherCommitID,
+				GitTreeState: "dirty",
+				Dirty:        true,
+				CommitTime:   "2024-06-01T10:30:00Z",
+				GoOS:         "linux",
+				GoArch:       "arm64",
+			},
+		},
+		{
+			comment:  "only version injected",
+			injected: Info{Version: "1.2.0"},
+			expected: Info{
+				Version:      "1.2.0",
+				GitCommit:    otherCommitID,
+				GitTreeState: "dirty",
+				Dirty:        true,
+				CommitTime:   "2024-06-01T10:30:00Z",
+				GoOS:         "linux",
+				GoArch:       "arm64",
+			},
+		},
+		{
+			comment:  "version and commit injected",
+			injected: Info{Version: "1.2.0", GitCommit: commitID, GitTreeState: "clean", BuildPlatform: "linux/amd64"},
+			expected: Info{
+				Version:       "1.2.0",
+				GitCommit:     commitID,
+				GitTreeState:  "clean",
+				CommitTime:    "2024-06-01T10:30:00Z",
+				BuildPlatform: "linux/amd64",
+			},
+		},
+		{
+			comment:  "everything injected",
+			injected: Info{Version: "1.2.0", GitCommit: commitID, GitTreeState: "clean", CommitTime: "2024-05-01T08:00:00Z", GoOS: "darwin", GoArch: "amd64"},
+			expected: Info{Version: "1.2.0", GitCommit: commitID, GitTreeState: "clean", CommitTime: "2024-05-01T08:00:00Z", GoOS: "darwin", GoArch: "amd64"},
+		},
+	}
+	for _, testCase := range testCases {
+		Set(testCase.injected)
+		info := Best()
+		// fields not subject to merging
+		testCase.expected.GoVersion = info.GoVersion
+		testCase.expected.ModulePath = "github.com/example/app"
+		if !reflect.DeepEqual(info, testCase.expected) {
+			t.Fatalf("%v: expected %+v but got %+v", testCase.comment, testCase.expected, info)
+		}
+	}
+
+	// The version of a development build from a source tree is not a gap filler
+	buildInfo.Main.Version = "(devel)"
+	Set(Info{})
+	if info := Best(); info.Version != "" {
+		t.Fatalf("expected no version for a development build but got %q", info.Version)
+	}
+
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return nil, false }
+	Set(Info{Version: "1.2.0"})
+	if info, expected := Best(), Get(); !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %+v without build information but got %+v", expected, info)
+	}
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import "strings"
+
+// Release channels
+const (
+	ChannelStable  = "stable"
+	ChannelBeta    = "beta"
+	ChannelAlpha   = "alpha"
+	ChannelRC      = "rc"
+	ChannelNightly = "nightly"
+	// ChannelDev denotes development builds: unversioned builds, builds from a dirty tree
+	// or from a commit past the most recent tag and builds with unrecognized prereleases
+	ChannelDev = "dev"
+)
+
+// prereleaseChannels maps the leading prerelease identifier to the release channel
+var prereleaseChannels = map[string]string{
+	"alpha":   ChannelAlpha,
+	"beta":    ChannelBeta,
+	"rc":      ChannelRC,
+	"nightly": ChannelNightly,
+}
+
+// Channel returns the release channel of the build derived from the prerelease of the version:
+// ChannelStable for releases and ChannelAlpha, ChannelBeta, ChannelRC or ChannelNightly for
+// prereleases like `1.2.0-beta.1`, `1.2.0-rc2` or `1.3.0-nightly.20240601.42`.
+// Development builds are in ChannelDev, including builds with `linkflags -minimal`
+// which carry no commit ID.
+func (r Info) Channel() string {
+	if r.Version == "" || r.GitCommit == defaultGitCommit || r.Dirty || r.GitTreeState == treeStateDirty {
+		return ChannelDev
+	}
+	parsed, err := ParseSemver(r.Version)
+	if err != nil || parsed.Metadata != "" {
+		return ChannelDev
+	}
+	if parsed.Prerelease == "" {
+		return ChannelStable
+	}
+	identifier := strings.ToLower(strings.SplitN(parsed.Prerelease, ".", 2)[0])
+	if channel, ok := prereleaseChannels[strings.TrimRight(identifier, "0123456789")]; ok {
+		return channel
+	}
+	return ChannelDev
+}
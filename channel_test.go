@@ -0,0 +1,36 @@
+package version
+
+import "testing"
+
+func TestChannel(t *testing.T) {
+	const commitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+
+	var testCases = []struct {
+		info     Info
+		expected string
+	}{
+		{Info{Version: "1.2.0", GitCommit: commitID}, ChannelStable},
+		{Info{Version: "v1.2.0", GitCommit: commitID, GitTreeState: "clean"}, ChannelStable},
+		{Info{Version: "1.2.0-alpha", GitCommit: commitID}, ChannelAlpha},
+		{Info{Version: "1.2.0-alpha.1", GitCommit: commitID}, ChannelAlpha},
+		{Info{Version: "1.2.0-beta.2", GitCommit: commitID}, ChannelBeta},
+		{Info{Version: "1.2.0-Beta3", GitCommit: commitID}, ChannelBeta},
+		{Info{Version: "1.2.0-rc.1", GitCommit: commitID}, ChannelRC},
+		{Info{Version: "1.2.0-rc1", GitCommit: commitID}, ChannelRC},
+		{Info{Version: "1.3.0-nightly.20240601.42", GitCommit: commitID}, ChannelNightly},
+		// development builds
+		{Info{Version: "1.2.0-preview.1", GitCommit: commitID}, ChannelDev},
+		{Info{Version: "1.2.0+2032d5b1a2b3c4", GitCommit: commitID}, ChannelDev},
+		{Info{Version: "1.2.0", GitCommit: commitID, GitTreeState: "dirty"}, ChannelDev},
+		{Info{Version: "1.2.0-rc.1", GitCommit: commitID, Dirty: true}, ChannelDev},
+		{Info{Version: "not-semver", GitCommit: commitID}, ChannelDev},
+		{Info{GitCommit: commitID}, ChannelDev},
+		// builds with -minimal only carry the version
+		{Info{Version: "1.2.0", GitCommit: defaultGitCommit}, ChannelDev},
+	}
+	for _, testCase := range testCases {
+		if channel := testCase.info.Channel(); channel != testCase.expected {
+			t.Fatalf("expected channel %v for %+v but got %v", testCase.expected, testCase.info, channel)
+		}
+	}
+}
@@ -16,15 +16,24 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/user"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"text/template"
+	"time"
+	"unicode"
 
 	"github.com/gravitational/version"
 	"github.com/gravitational/version/pkg/tool"
@@ -38,6 +47,17 @@ var pkg = flag.String("pkg", "", "root package path")
 // This flag is useful when the version package is custom-vendored and has a different package path.
 var versionPackage = flag.String("verpkg", "github.com/gravitational/version", "path to the version package")
 
+// workTree and gitDir override the git working tree and repository directory
+// which are otherwise derived from pkg.
+// They are useful when the repository is stored separately from the working tree, e.g. in CI caches.
+var workTree = flag.String("work-tree", "", "path to the git working tree (defaults to -pkg)")
+
+var gitDir = flag.String("git-dir", "", "path to the git repository (defaults to .git in the working tree)")
+
+// detectVendor enables warnings about vendored copies of the version package:
+// the linker flags must use the original import path as vendoring does not change import paths.
+var detectVendor = flag.Bool("detect-vendor", false, "warn about a vendored copy of the version package and vendor paths in -verpkg")
+
 var compatMode = flag.Bool("compat", false, "generate linker flags using go1.4 syntax")
 
 var tagOnly = flag.Bool("tag", false, "print tag only")
@@ -46,24 +66,157 @@ var release = flag.Bool("os-release", false, "print version with OS and architec
 
 var dockerTag = flag.Bool("docker-tag", false, "print version compatible with docker tag requirements")
 
-// semverPattern defines a regexp pattern to modify the results of `git describe` to be semver-complaint.
-//
-// it matches versions like: 3.13.0-3-g2032d5b
-var semverPattern = regexp.MustCompile(`([0-9]+)\.([0-9]+)\.([0-9]+)-([0-9]{1,})-g([0-9a-f]{14})$`)
+var scope = flag.String("tree-state-scope", string(scopeBoth), "changes considered for the git tree state: worktree, index or both")
+
+var abbrev = flag.Int("abbrev", 14, "number of hexadecimal digits of the abbreviated commit ID in the version")
+
+var abbrevUnique = flag.Bool("abbrev-unique", false, "increase -abbrev if required to uniquely identify the commit")
+
+var includePlatform = flag.Bool("include-platform", false, "emit the target operating system and architecture")
+
+var targets = flag.String("targets", "",
+	"comma-separated list of os/arch targets to print a JSON manifest with the linker flags for each target instead")
+
+var includeTreeHash = flag.Bool("include-tree-hash", false, "emit the hash of the source tree object")
+
+var includeIgnored = flag.Bool("include-ignored", false, "consider files ignored by git when determining the git tree state")
+
+var format = flag.String("format", formatFlags, "output format: flags, ko (YAML list for the ldflags of .ko.yaml), describe (raw `git describe` output of HEAD), "+
+	"sbom-fragment (SPDX package fields in JSON), provenance (SLSA provenance predicate stub in JSON) or "+
+	"dotenv (environment variables for the .env file of Docker Compose)")
+
+var builder = flag.String("builder", "", "ID of the builder recorded with -format=provenance")
+
+var buildURL = flag.String("build-url", "", "URL of the build (e.g. the CI job) recorded as the build invocation ID with -format=provenance")
+
+var includeTreeDirty = flag.Bool("include-tree-dirty", false,
+	"additionally emit the tree state as the boolean gitTreeDirty for consumers preferring it to gitTreeState")
+
+var includeCommitTime = flag.Bool("include-commit-time", false, "emit the time of the commit")
+
+var atTag = flag.String("at-tag", "", "compute the version for the commit of the named tag instead of HEAD")
+
+// describeStrategy selects the tag the version is based on:
+//   - git uses the tag chosen by `git describe --tags`: the tag with the fewest commits
+//     between it and the commit, regardless of its name or version.
+//   - semver-highest uses the highest semver tag among all tags merged into the commit,
+//     including tags on merged side branches.
+//   - semver-nearest uses the semver tag merged into the commit with the fewest commits
+//     between it and the commit, preferring the higher version on ties. Unlike git,
+//     it ignores tags that are not semantic versions.
+var describeStrategy = flag.String("describe-strategy", strategyGit, "tag the version is based on: "+
+	"git (tag chosen by `git describe`), semver-highest (highest semver tag merged into the commit) or "+
+	"semver-nearest (closest semver tag merged into the commit)")
+
+// merged is a shorthand for -describe-strategy=semver-highest.
+var merged = flag.Bool("merged", false, "shorthand for -describe-strategy=semver-highest")
+
+var dotfile = flag.String("dotfile", "", "additionally write the version to the specified file for non-Go consumers")
+
+var dotfileCommit = flag.Bool("dotfile-commit", false, "write the git commit to the second line of the -dotfile")
+
+var includeBuildInfo = flag.Bool("include-build-info", false, "emit the build time, user and host (build time honors SOURCE_DATE_EPOCH)")
+
+var versionTemplate = flag.String("version-template", "",
+	"text/template for the version with fields {{.Tag}}, {{.Commits}}, {{.SHA}} and {{.Dirty}} used instead of the semver-compliant default")
+
+var long = flag.Bool("long", false, "describe tagged commits with the number of commits (0) and the commit ID for uniform versions")
+
+var semverStrict = flag.Bool("semver-strict", false, "fail if the computed version is not a valid semantic version instead of emitting it verbatim")
+
+var includeModulePath = flag.Bool("include-module-path", false,
+	"emit the path of the main Go module (otherwise the version package reads it from the build information of the binary)")
+
+var includeSubmodules = flag.Bool("include-submodules", false, "emit the paths and commits of git submodules")
+
+var encode = flag.String("encode", "", "encoding of the values of the linker flags: none or base64 (decoded by the version package)")
+
+var minimal = flag.Bool("minimal", false, "emit only the version for size-constrained binaries, ignoring all other information "+
+	"(without the commit such builds are development builds for IsDevBuild and Info.Channel)")
+
+var showBanner = flag.Bool("banner", false, "print a human-readable summary of the version to stderr")
+
+var fd = flag.Int("fd", 1, "file descriptor to write the output to instead of stdout, e.g. one reserved by the build system")
+
+var quiet = flag.Bool("quiet", false, "suppress warnings")
+
+var stateFile = flag.String("state-file", "",
+	"cache the linker flags in the specified file and reuse them while the commit and the clean tree state are unchanged, "+
+		"exiting with status 3")
+
+var onlyIfNewer = flag.String("only-if-newer", "",
+	"emit output only if the version is strictly newer than the specified baseline tag, otherwise exit with status 4")
+
+var edition = flag.String("edition", "", "product edition: "+strings.Join(version.Editions, " or "))
+
+var attrs = make(attrFlag)
+
+func init() {
+	flag.Var(attrs, "attr", "custom build attribute as `key=value`, may be repeated")
+}
+
+// cgoSymbol is not supported: see errCgoSymbol.
+var cgoSymbol = flag.String("cgo-symbol", "", "unsupported: the go linker cannot set C-visible variables")
+
+// verifySymbols names a binary built with the linker flags to verify that the variables
+// they set exist: the linker silently ignores flags for variables it does not know,
+// e.g. if -verpkg is wrong or the program does not use the version package.
+var verifySymbols = flag.String("verify-symbols", "", "verify with `go tool nm` that the variables set by the linker flags exist in the specified binary")
+
+// varPrefix is prepended to the names of the variables set by the linker flags.
+// The package given with -verpkg must declare the variables with the prefixed names.
+var varPrefix = flag.String("symbol-prefix", "", "prefix of the names of the variables set in the version package (see README.md)")
+
+var splitVersion = flag.Bool("split-version", false, "additionally emit major, minor and patch version components as separate variables")
+
+// goVersionOverride selects the linker flag syntax for the specified version of the go tool
+// instead of the version detected with `go version`.
+var goVersionOverride = flag.String("go-version", "", "version of the go tool the linker flags are generated for, e.g. go1.22 (detected by default)")
 
 // goVersionPattern defines a regexp pattern to parse versions of the `go tool`.
 var goVersionPattern = regexp.MustCompile(`go([1-9])\.(\d+)(?:.\d+)*`)
 
+// attrKeyPattern defines the keys accepted for custom build attributes.
+var attrKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// varPrefixPattern defines the prefixes accepted with -symbol-prefix:
+// the prefixed variable names must remain valid Go identifiers.
+var varPrefixPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 // dockerTagAntiPattern matches all chars not accepted by docker tag requirements
 var dockerTagAntiPattern = regexp.MustCompile(`[^\w-_.]`)
 
 func main() {
-	if err := run(); err != nil {
+	switch err := run(); err {
+	case nil:
+	case errUnchanged:
+		os.Exit(exitUnchanged)
+	case errNotNewer:
+		os.Exit(exitNotNewer)
+	default:
 		log.Fatalln(err)
 	}
 }
 
-func run() error {
+// errUnchanged is returned by run when the cached linker flags from -state-file
+// have been emitted as the git state has not changed since they were computed
+var errUnchanged = errors.New("git state unchanged")
+
+// errNotNewer is returned by run when the version is not newer than the -only-if-newer baseline
+var errNotNewer = errors.New("version not newer than baseline")
+
+// errCgoSymbol explains why the version cannot be injected into C-visible variables
+var errCgoSymbol = errors.New("-cgo-symbol is not supported: `-X` only sets Go string variables and " +
+	"cannot initialize variables declared in C code or symbols of other types; " +
+	"expose the version to C with an exported Go function instead (see README.md)")
+
+// exitUnchanged is the exit status signaling that the cached linker flags have been emitted
+const exitUnchanged = 3
+
+// exitNotNewer is the exit status signaling that the version is not newer than the -only-if-newer baseline
+const exitNotNewer = 4
+
+func run() (err error) {
 	log.SetFlags(0)
 	flag.Parse()
 	if *pkg == "" {
@@ -74,81 +227,996 @@ func run() error {
 		*pkg = dir
 	}
 
-	goVersion, err := goToolVersion()
+	switch treeStateScope(*scope) {
+	case scopeWorktree, scopeIndex, scopeBoth:
+	default:
+		return fmt.Errorf("invalid tree state scope %q: expected one of worktree, index or both", *scope)
+	}
+
+	if !validFormat(*format) {
+		return fmt.Errorf("invalid output format %q: expected one of %v", *format, strings.Join(formats, ", "))
+	}
+
+	if *cgoSymbol != "" {
+		return errCgoSymbol
+	}
+
+	if *encode != "" && *encode != encodingBase64 {
+		return fmt.Errorf("invalid encoding %q: expected %v", *encode, encodingBase64)
+	}
+
+	if *edition != "" && !version.ValidEdition(*edition) {
+		return fmt.Errorf("invalid edition %q: expected one of %v", *edition, strings.Join(version.Editions, ", "))
+	}
+
+	if *varPrefix != "" && !varPrefixPattern.MatchString(*varPrefix) {
+		return fmt.Errorf("invalid symbol prefix %q: expected letters, digits or `_` not starting with a digit", *varPrefix)
+	}
+
+	if *onlyIfNewer != "" {
+		if _, err := version.ParseSemver(*onlyIfNewer); err != nil {
+			return fmt.Errorf("invalid baseline version %q: %v", *onlyIfNewer, err)
+		}
+	}
+
+	if *versionTemplate != "" {
+		if _, err := template.New("version").Parse(*versionTemplate); err != nil {
+			return fmt.Errorf("invalid version template: %v", err)
+		}
+	}
+
+	var platforms [][2]string
+	if *targets != "" {
+		var err error
+		if platforms, err = parseTargets(*targets); err != nil {
+			return err
+		}
+	}
+
+	switch *describeStrategy {
+	case strategyGit, strategySemverHighest, strategySemverNearest:
+	default:
+		return fmt.Errorf("invalid describe strategy %q: expected one of %v, %v or %v",
+			*describeStrategy, strategyGit, strategySemverHighest, strategySemverNearest)
+	}
+	if *merged && *describeStrategy == strategySemverNearest {
+		return fmt.Errorf("-merged conflicts with -describe-strategy=%v", *describeStrategy)
+	}
+	// The verbatim output of `git describe` describes HEAD with the default strategy
+	if *format == formatDescribe {
+		switch {
+		case *atTag != "":
+			return fmt.Errorf("-at-tag conflicts with -format=%v", formatDescribe)
+		case *describeStrategy != strategyGit:
+			return fmt.Errorf("-describe-strategy=%v conflicts with -format=%v", *describeStrategy, formatDescribe)
+		case *merged:
+			return fmt.Errorf("-merged conflicts with -format=%v", formatDescribe)
+		case *abbrevUnique:
+			return fmt.Errorf("-abbrev-unique conflicts with -format=%v", formatDescribe)
+		}
+	}
+
+	if *abbrev < minAbbrev || *abbrev > maxAbbrev {
+		return fmt.Errorf("invalid abbreviation length %v: expected a value between %v and %v", *abbrev, minAbbrev, maxAbbrev)
+	}
+
+	out, err := outputFile(*fd)
 	if err != nil {
+		return err
+	}
+	if out != os.Stdout && out != os.Stderr {
+		defer func() {
+			if closeErr := out.Close(); closeErr != nil && err == nil {
+				err = fmt.Errorf("failed to close file descriptor %v: %v\n", *fd, closeErr)
+			}
+		}()
+	}
+
+	if *workTree == "" {
+		*workTree = *pkg
+	}
+	if *gitDir == "" {
+		*gitDir = filepath.Join(*workTree, ".git")
+	}
+	for _, dir := range []string{*workTree, *gitDir} {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("invalid git directory: %v", err)
+		}
+	}
+	git := newGit(*workTree, *gitDir)
+
+	if *detectVendor {
+		checkVendor(*workTree, *versionPackage)
+	}
+
+	if *format == formatDescribe {
+		describe, err := git.describe(*abbrev)
+		if err != nil {
+			return fmt.Errorf("failed to describe git tree: %v\n", err)
+		}
+		fmt.Fprint(out, describe)
+		return nil
+	}
+
+	detectedVersion, err := goToolVersion()
+	if err != nil && *goVersionOverride == "" {
 		return fmt.Errorf("failed to determine go tool version: %v\n", err)
 	}
+	goVersion, err := selectToolVersion(*goVersionOverride, detectedVersion)
+	if err != nil {
+		return err
+	}
+	if goVersion == toolVersionUnknown {
+		warnf("unknown go tool version, falling back to go1.4 linker flag syntax")
+	}
 
-	info, err := getVersionInfo(*pkg)
+	// The state file only caches linker flags: other outputs are always computed.
+	// The cache is bypassed for flags with side effects (-dotfile, -banner, -verify-symbols) or
+	// values not recorded in the state (-include-build-info, -include-platform) that must not
+	// be skipped or reused.
+	cacheFlags := *stateFile != "" && *onlyIfNewer == "" && !*tagOnly && !*release && !*dockerTag &&
+		(*format == formatFlags || *format == formatKo) && len(platforms) == 0 &&
+		*dotfile == "" && !*showBanner && !*includeBuildInfo && *verifySymbols == "" && !*includePlatform
+	var state *buildState
+	if cacheFlags {
+		state, err = currentBuildState(git, goVersion, os.Args[1:])
+		if err != nil {
+			return fmt.Errorf("failed to determine git state: %v\n", err)
+		}
+		cached, err := readBuildState(*stateFile)
+		if err != nil {
+			return fmt.Errorf("failed to read state from %v: %v\n", *stateFile, err)
+		}
+		if cached != nil && cached.unchanged(*state) {
+			fmt.Fprintf(out, "%s", cached.Flags)
+			return errUnchanged
+		}
+	}
+
+	info, err := getVersionInfo(git)
 	if err != nil {
 		return fmt.Errorf("failed to determine version information: %v\n", err)
 	}
 
+	if *onlyIfNewer != "" {
+		isNewer, err := newer(info.Version, *onlyIfNewer)
+		if err != nil {
+			return fmt.Errorf("failed to compare version with baseline: %v\n", err)
+		}
+		if !isNewer {
+			return errNotNewer
+		}
+	}
+
+	info.Edition = *edition
+	if len(attrs) != 0 {
+		info.Attrs = attrs
+	}
+
+	if *includeModulePath {
+		info.ModulePath, err = goModulePath(*pkg)
+		if err != nil {
+			return fmt.Errorf("failed to determine module path: %v\n", err)
+		}
+	}
+
+	if *showBanner {
+		log.Println(banner(info))
+	}
+
+	if *includeBuildInfo {
+		if err = setBuildInfo(info, os.Getenv("SOURCE_DATE_EPOCH"), time.Now()); err != nil {
+			return fmt.Errorf("failed to determine build information: %v\n", err)
+		}
+	}
+
+	if *dotfile != "" {
+		if err = writeDotfile(*dotfile, info, *dotfileCommit); err != nil {
+			return fmt.Errorf("failed to write version to %v: %v\n", *dotfile, err)
+		}
+	}
+
 	// print just tag and return
 	if *tagOnly {
-		fmt.Printf(info.Version)
+		fmt.Fprint(out, info.Version)
 		return nil
 	}
 
 	if *release {
-		fmt.Printf("%v-%v-%v", info.Version, runtime.GOOS, runtime.GOARCH)
+		fmt.Fprintf(out, "%v-%v-%v", info.Version, runtime.GOOS, runtime.GOARCH)
 		return nil
 	}
 
 	if *dockerTag {
-		fmt.Printf("%v", dockerTagAntiPattern.ReplaceAllString(info.Version, "-"))
+		fmt.Fprintf(out, "%v", dockerTagAntiPattern.ReplaceAllString(info.Version, "-"))
 		return nil
 	}
 
-	var linkFlags []string
-	linkFlag := func(key, value string) string {
+	if *format == formatSBOM {
+		remote, err := git.remoteURL()
+		if err != nil {
+			remote = ""
+		}
+		payload, err := sbomFragment(filepath.Base(*pkg), info, remote)
+		if err != nil {
+			return fmt.Errorf("failed to generate SBOM fragment: %v\n", err)
+		}
+		fmt.Fprintf(out, "%s", payload)
+		return nil
+	}
+
+	if *format == formatProvenance {
+		remote, err := git.remoteURL()
+		if err != nil {
+			remote = ""
+		}
+		payload, err := provenancePredicate(info, remote, *builder, *buildURL)
+		if err != nil {
+			return fmt.Errorf("failed to generate provenance predicate: %v\n", err)
+		}
+		fmt.Fprintf(out, "%s", payload)
+		return nil
+	}
+
+	if *format == formatDotenv {
+		fmt.Fprint(out, dotenv(info))
+		return nil
+	}
+
+	if *splitVersion {
+		splitVersionInfo(info)
+	}
+
+	if len(platforms) != 0 {
+		payload, err := targetsManifest(info, platforms, goVersion)
+		if err != nil {
+			return fmt.Errorf("failed to generate targets manifest: %v\n", err)
+		}
+		fmt.Fprintf(out, "%s", payload)
+		return nil
+	}
+
+	if *includePlatform {
+		info.GoOS, info.GoArch, err = goTargetPlatform()
+		if err != nil {
+			return fmt.Errorf("failed to determine target platform: %v\n", err)
+		}
+	}
+
+	if *minimal {
+		info = minimalInfo(info)
+	}
+
+	if *verifySymbols != "" {
+		if err = verifyBinarySymbols(*verifySymbols, linkFlags(info, goVersion)); err != nil {
+			return err
+		}
+	}
+
+	flags := formatLinkFlags(linkFlags(info, goVersion), *format)
+	if cacheFlags {
+		state.Flags = flags
+		if err = writeBuildState(*stateFile, *state); err != nil {
+			return fmt.Errorf("failed to write state to %v: %v\n", *stateFile, err)
+		}
+	}
+	fmt.Fprintf(out, "%s", flags)
+	return nil
+}
+
+// attrFlag collects custom build attributes given as repeated `-attr key=value` flags.
+type attrFlag map[string]string
+
+func (r attrFlag) String() string {
+	var pairs []string
+	for key, value := range r {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the attribute given as key=value.
+func (r attrFlag) Set(attr string) error {
+	parts := strings.SplitN(attr, "=", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("expected key=value but got %q", attr)
+	}
+	key, value := parts[0], parts[1]
+	if !attrKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid key %q: expected letters, digits, `_`, `.` or `-` starting with a letter or `_`", key)
+	}
+	for _, c := range value {
+		if unicode.IsControl(c) {
+			return fmt.Errorf("invalid value of %v: control character %q", key, c)
+		}
+	}
+	r[key] = value
+	return nil
+}
+
+// outputFile returns the file for the output: stdout or the file descriptor fd
+// inherited from the parent process.
+// Stdout and stderr are returned as is so that they are not closed with the output.
+func outputFile(fd int) (*os.File, error) {
+	switch fd {
+	case 1:
+		return os.Stdout, nil
+	case 2:
+		return os.Stderr, nil
+	}
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid file descriptor %v", fd)
+	}
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd %v", fd))
+	// Writing zero bytes fails for descriptors that are not open for writing
+	if _, err := file.Write(nil); err != nil {
+		return nil, fmt.Errorf("file descriptor %v is not writable: %v", fd, err)
+	}
+	return file, nil
+}
+
+// newer determines if current is strictly newer than baseline using semver precedence.
+func newer(current, baseline string) (bool, error) {
+	result, err := version.Compare(current, baseline)
+	if err != nil {
+		return false, err
+	}
+	return result > 0, nil
+}
+
+// buildState records the git state the linker flags have been computed for.
+type buildState struct {
+	// Commit is the ID of the commit the version has been computed for
+	Commit string `json:"commit"`
+	// TreeState is the state of the git tree
+	TreeState treeState `json:"treeState"`
+	// GoVersion is the version of the go tool that determines the syntax of the linker flags
+	GoVersion toolVersion `json:"goVersion"`
+	// Args lists the command line arguments of the tool
+	Args []string `json:"args"`
+	// Flags are the linker flags computed for this state
+	Flags string `json:"flags,omitempty"`
+}
+
+// unchanged determines if the cached flags of this state are valid for the current state.
+// Changes to a dirty tree cannot be detected without recomputing the version,
+// hence only states of a clean tree are considered unchanged.
+func (r buildState) unchanged(current buildState) bool {
+	return r.Commit == current.Commit && r.TreeState == clean && current.TreeState == clean &&
+		r.GoVersion == current.GoVersion && strings.Join(r.Args, "\x00") == strings.Join(current.Args, "\x00")
+}
+
+// currentBuildState determines the git state the version is computed for
+// when the tool is invoked with the specified arguments for the go tool of version goVersion.
+func currentBuildState(git *git, goVersion toolVersion, args []string) (*buildState, error) {
+	commitID, err := targetCommitID(git)
+	if err != nil {
+		return nil, err
+	}
+	treeState, err := git.treeState(treeStateScope(*scope), *includeIgnored)
+	if err != nil {
+		return nil, err
+	}
+	return &buildState{Commit: commitID, TreeState: treeState, GoVersion: goVersion, Args: args}, nil
+}
+
+// readBuildState reads the build state from the file at path.
+// It returns nil if the file does not exist.
+func readBuildState(path string) (*buildState, error) {
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state buildState
+	if err = json.Unmarshal(contents, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// writeBuildState writes the build state to the file at path.
+func writeBuildState(path string, state buildState) error {
+	contents, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, contents, 0644)
+}
+
+// minimalInfo returns the subset of info emitted with -minimal: the version.
+func minimalInfo(info *version.Info) *version.Info {
+	return &version.Info{Version: info.Version}
+}
+
+// banner returns a one-line human-readable summary of the version information.
+func banner(info *version.Info) string {
+	result := "version " + info.Version
+	if info.Version == "" {
+		result = "no version"
+	}
+	var details []string
+	if info.GitCommit != "" {
+		details = append(details, "commit "+info.GitCommit)
+	}
+	if info.GitTreeState == dirty {
+		details = append(details, dirty)
+	}
+	if len(details) != 0 {
+		result += " (" + strings.Join(details, ", ") + ")"
+	}
+	return result
+}
+
+// formatLinkFlags renders the linker flags in the specified output format.
+func formatLinkFlags(flags []string, format string) string {
+	switch format {
+	case formatKo:
+		// YAML list of double-quoted strings to be used for `ldflags` in .ko.yaml
+		var buf bytes.Buffer
+		for _, linkFlag := range flags {
+			fmt.Fprintf(&buf, "- %s\n", strconv.Quote(linkFlag))
+		}
+		return buf.String()
+	default:
+		return strings.Join(flags, " ")
+	}
+}
+
+// setBuildInfo populates the build time, user and host of info.
+// The build time is derived from sourceDateEpoch (the value of SOURCE_DATE_EPOCH
+// environment variable) if set and from now otherwise.
+func setBuildInfo(info *version.Info, sourceDateEpoch string, now time.Time) error {
+	info.BuildTime = now.UTC().Format(time.RFC3339)
+	info.BuildTimeSource = version.BuildTimeSourceClock
+	if sourceDateEpoch != "" {
+		seconds, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", sourceDateEpoch, err)
+		}
+		info.BuildTime = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+		info.BuildTimeSource = version.BuildTimeSourceEpoch
+	}
+	if current, err := user.Current(); err == nil {
+		info.BuildUser = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		info.BuildHost = host
+	}
+	return nil
+}
+
+// writeDotfile writes the version from info to the file at path.
+// If includeCommit is true, the git commit is written on the second line.
+// Every line is terminated with a newline.
+func writeDotfile(path string, info *version.Info, includeCommit bool) error {
+	contents := info.Version + "\n"
+	if includeCommit {
+		contents += info.GitCommit + "\n"
+	}
+	return os.WriteFile(path, []byte(contents), 0644)
+}
+
+// spdxPackage defines a subset of the fields of an SPDX package.
+// See https://spdx.github.io/spdx-spec/v2.3/package-information/
+type spdxPackage struct {
+	Name             string `json:"name"`
+	VersionInfo      string `json:"versionInfo"`
+	DownloadLocation string `json:"downloadLocation"`
+	SourceInfo       string `json:"sourceInfo"`
+}
+
+// spdxNoAssertion is the SPDX value for information that has not been determined
+const spdxNoAssertion = "NOASSERTION"
+
+// sbomFragment returns the JSON-encoded SPDX package fields of package name
+// with version information info obtained from the repository at remote.
+func sbomFragment(name string, info *version.Info, remote string) ([]byte, error) {
+	spdx := spdxPackage{
+		Name:             name,
+		VersionInfo:      info.Version,
+		DownloadLocation: spdxNoAssertion,
+		SourceInfo:       fmt.Sprintf("built from git commit %v", info.GitCommit),
+	}
+	if spdx.VersionInfo == "" {
+		spdx.VersionInfo = spdxNoAssertion
+	}
+	if remote != "" {
+		spdx.DownloadLocation = fmt.Sprintf("git+%v@%v", remoteToURL(remote), info.GitCommit)
+	}
+	return json.MarshalIndent(spdx, "", "  ")
+}
+
+// manifest lists the linker flags for multiple targets of a build.
+type manifest struct {
+	Version   string           `json:"version"`
+	GitCommit string           `json:"gitCommit"`
+	Targets   []manifestTarget `json:"targets"`
+}
+
+// manifestTarget defines the linker flags for a single target.
+type manifestTarget struct {
+	GoOS    string `json:"goos"`
+	GoArch  string `json:"goarch"`
+	LDFlags string `json:"ldflags"`
+}
+
+// parseTargets parses a comma-separated list of os/arch targets.
+func parseTargets(value string) ([][2]string, error) {
+	var platforms [][2]string
+	for _, target := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(target), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid target %q: expected os/arch, e.g. linux/amd64", target)
+		}
+		platforms = append(platforms, [2]string{parts[0], parts[1]})
+	}
+	return platforms, nil
+}
+
+// targetsManifest returns the JSON-encoded manifest with the linker flags
+// for the build with version information info for each of the platforms.
+func targetsManifest(info *version.Info, platforms [][2]string, goVersion toolVersion) ([]byte, error) {
+	result := manifest{Version: info.Version, GitCommit: info.GitCommit}
+	for _, platform := range platforms {
+		target := *info
+		target.GoOS, target.GoArch = platform[0], platform[1]
+		result.Targets = append(result.Targets, manifestTarget{
+			GoOS:    target.GoOS,
+			GoArch:  target.GoArch,
+			LDFlags: strings.Join(linkFlags(&target, goVersion), " "),
+		})
+	}
+	return json.MarshalIndent(result, "", "  ")
+}
+
+// dotenv returns the version information as environment variables
+// in the .env file format of Docker Compose.
+func dotenv(info *version.Info) string {
+	var buf bytes.Buffer
+	for _, variable := range []struct{ name, value string }{
+		{"VERSION", info.Version},
+		{"GIT_COMMIT", info.GitCommit},
+		{"GIT_TREE_STATE", info.GitTreeState},
+		{"GIT_BRANCH", info.GitBranch},
+	} {
+		fmt.Fprintf(&buf, "%v=%v\n", variable.name, dotenvQuote(variable.value))
+	}
+	return buf.String()
+}
+
+// dotenvUnquotedPattern matches values that do not require quoting in .env files
+var dotenvUnquotedPattern = regexp.MustCompile(`^[\w.+/:@-]*$`)
+
+// dotenvQuote quotes value for a .env file so that it is read verbatim.
+// Values are single-quoted to prevent interpolation unless they contain single quotes
+// in which case they are double-quoted with `\`, `"`, `$` and newlines escaped.
+func dotenvQuote(value string) string {
+	switch {
+	case dotenvUnquotedPattern.MatchString(value):
+		return value
+	case !strings.Contains(value, "'"):
+		return "'" + value + "'"
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// provenanceBuildType identifies the build process in provenance predicates
+const provenanceBuildType = "https://github.com/gravitational/version/linkflags@v1"
+
+// provenance defines a minimal SLSA provenance predicate.
+// See https://slsa.dev/spec/v0.2/provenance
+type provenance struct {
+	Builder   provenanceBuilder    `json:"builder"`
+	BuildType string               `json:"buildType"`
+	Metadata  *provenanceMetadata  `json:"metadata,omitempty"`
+	Materials []provenanceMaterial `json:"materials"`
+}
+
+// provenanceBuilder identifies the builder.
+type provenanceBuilder struct {
+	ID string `json:"id"`
+}
+
+// provenanceMetadata describes the build invocation.
+type provenanceMetadata struct {
+	// BuildInvocationID identifies the build invocation, e.g. with the URL of the CI job
+	BuildInvocationID string `json:"buildInvocationId"`
+}
+
+// provenanceMaterial describes a source the build has been made from.
+type provenanceMaterial struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+// provenancePredicate returns the JSON-encoded provenance predicate for the build with
+// version information info obtained from the repository at remote.
+// The predicate is a stub to be completed and signed by a later step of the build.
+func provenancePredicate(info *version.Info, remote, builder, buildURL string) ([]byte, error) {
+	material := provenanceMaterial{
+		Digest: map[string]string{"sha1": info.GitCommit},
+	}
+	if remote != "" {
+		material.URI = "git+" + remoteToURL(remote)
+	}
+	predicate := provenance{
+		Builder:   provenanceBuilder{ID: builder},
+		BuildType: provenanceBuildType,
+		Materials: []provenanceMaterial{material},
+	}
+	if buildURL != "" {
+		predicate.Metadata = &provenanceMetadata{BuildInvocationID: buildURL}
+	}
+	return json.MarshalIndent(predicate, "", "  ")
+}
+
+// remoteToURL converts the scp-like syntax of a git remote (`user@host:path`) to an ssh URL.
+// Remotes given as URLs are returned unchanged.
+func remoteToURL(remote string) string {
+	if strings.Contains(remote, "://") {
+		return remote
+	}
+	if i := strings.Index(remote, ":"); i > 0 {
+		return "ssh://" + remote[:i] + "/" + remote[i+1:]
+	}
+	return remote
+}
+
+// linkFlags returns the linker flags to set the version information given with info.
+func linkFlags(info *version.Info, goVersion toolVersion) []string {
+	var flags []string
+	format := func(key, value string) (string, bool) {
 		if goVersion <= 14 || *compatMode {
-			return fmt.Sprintf("-X %s.%s %s", *versionPackage, key, value)
+			arg, ok := quoteArg(value)
+			return fmt.Sprintf("-X %s.%s %s", *versionPackage, key, arg), ok
 		}
-		return fmt.Sprintf("-X %s.%s=%s", *versionPackage, key, value)
+		arg, ok := quoteArg(fmt.Sprintf("%s.%s=%s", *versionPackage, key, value))
+		return fmt.Sprintf("-X %s", arg), ok
+	}
+	linkFlag := func(key, value string) string {
+		key = *varPrefix + key
+		if *encode == encodingBase64 {
+			value = encodeValue(value)
+		}
+		result, ok := format(key, value)
+		if !ok {
+			// The version package decodes values that go build could not split otherwise
+			result, _ = format(key, encodeValue(value))
+		}
+		return result
 	}
 
 	// Determine the values of version-related variables as commands to the go linker.
 	if info.GitCommit != "" {
-		linkFlags = append(linkFlags, linkFlag("gitCommit", info.GitCommit))
-		linkFlags = append(linkFlags, linkFlag("gitTreeState", info.GitTreeState))
+		flags = append(flags, linkFlag("gitCommit", info.GitCommit))
+		flags = append(flags, linkFlag("gitTreeState", info.GitTreeState))
+		if *includeTreeDirty {
+			flags = append(flags, linkFlag("gitTreeDirty", strconv.FormatBool(info.GitTreeState == dirty)))
+		}
+	}
+	if info.GitBranch != "" {
+		flags = append(flags, linkFlag("gitBranch", info.GitBranch))
 	}
 	if info.Version != "" {
-		linkFlags = append(linkFlags, linkFlag("version", info.Version))
+		flags = append(flags, linkFlag("version", info.Version))
+	}
+	if info.SourceTreeHash != "" {
+		flags = append(flags, linkFlag("sourceTreeHash", info.SourceTreeHash))
+	}
+	if info.CommitTime != "" {
+		flags = append(flags, linkFlag("commitTime", info.CommitTime))
 	}
+	if info.BuildTime != "" {
+		flags = append(flags, linkFlag("buildTime", info.BuildTime))
+		flags = append(flags, linkFlag("buildTimeSource", info.BuildTimeSource))
+	}
+	if info.BuildUser != "" {
+		flags = append(flags, linkFlag("buildUser", info.BuildUser))
+	}
+	if info.BuildHost != "" {
+		flags = append(flags, linkFlag("buildHost", info.BuildHost))
+	}
+	if len(info.Submodules) != 0 {
+		payload, err := json.Marshal(info.Submodules)
+		if err == nil {
+			flags = append(flags, linkFlag("gitSubmodules", string(payload)))
+		}
+	}
+	if info.Edition != "" {
+		flags = append(flags, linkFlag("edition", info.Edition))
+	}
+	if info.ModulePath != "" {
+		flags = append(flags, linkFlag("modulePath", info.ModulePath))
+	}
+	if len(info.Attrs) != 0 {
+		payload, err := json.Marshal(info.Attrs)
+		if err == nil {
+			flags = append(flags, linkFlag("buildAttrs", string(payload)))
+		}
+	}
+	if info.GoOS != "" {
+		flags = append(flags, linkFlag("goOS", info.GoOS))
+		flags = append(flags, linkFlag("goArch", info.GoArch))
+	}
+	if info.VersionMajor != "" {
+		flags = append(flags, linkFlag("versionMajor", info.VersionMajor))
+		flags = append(flags, linkFlag("versionMinor", info.VersionMinor))
+		flags = append(flags, linkFlag("versionPatch", info.VersionPatch))
+	}
+	return flags
+}
+
+// encodeValue encodes value with base64 for the version package to decode.
+func encodeValue(value string) string {
+	return encodedValuePrefix + base64.StdEncoding.EncodeToString([]byte(value))
+}
 
-	fmt.Printf("%s", strings.Join(linkFlags, " "))
+// quoteArg quotes arg for the `go build -ldflags` argument if arg contains whitespace
+// or starts with a quote.
+// go build splits -ldflags at whitespace outside of single or double quotes
+// without support for escaping, hence arg cannot be quoted if it contains both kinds of quotes:
+// quoteArg returns false in this case.
+func quoteArg(arg string) (string, bool) {
+	if !strings.ContainsAny(arg, " \t\r\n") && !strings.HasPrefix(arg, "'") && !strings.HasPrefix(arg, `"`) {
+		return arg, true
+	}
+	if !strings.Contains(arg, "'") {
+		return "'" + arg + "'", true
+	}
+	if !strings.Contains(arg, `"`) {
+		return `"` + arg + `"`, true
+	}
+	return "", false
+}
+
+// checkVendor warns if the version package is vendored in the directory dir or
+// if versionPkg, the import path used for the linker flags, refers to a vendor directory.
+// Vendoring does not change import paths, hence the linker flags must always use
+// the original import path of the version package.
+func checkVendor(dir, versionPkg string) {
+	if i := strings.Index("/"+versionPkg, "/vendor/"); i >= 0 {
+		warnf("-verpkg %v refers to a vendor directory: the linker flags have no effect, use the import path %v instead",
+			versionPkg, versionPkg[i+len("vendor/"):])
+		return
+	}
+	vendored := filepath.Join(dir, "vendor", filepath.FromSlash(versionPkg))
+	if _, err := os.Stat(vendored); err == nil {
+		warnf("found the version package vendored in %v: the linker flags use its import path %v as vendoring does not change import paths",
+			vendored, versionPkg)
+	}
+}
+
+// verifyBinarySymbols verifies that the variables set by the linker flags exist in the binary at path.
+func verifyBinarySymbols(path string, flags []string) error {
+	goTool := &tool.T{Cmd: "go"}
+	out, err := goTool.Exec("tool", "nm", path)
+	if err != nil {
+		return fmt.Errorf("failed to list symbols of %v: %v\n", path, err)
+	}
+	if missing := missingSymbols(out, flagSymbols(flags)); len(missing) != 0 {
+		return fmt.Errorf("variables %v do not exist in %v: the linker flags have no effect", strings.Join(missing, ", "), path)
+	}
 	return nil
 }
 
-// getVersionInfo collects the build version information for package pkg.
-func getVersionInfo(pkg string) (*version.Info, error) {
-	git := newGit(pkg)
-	commitID, err := git.commitID()
+// flagSymbols returns the names of the symbols of the variables set by the linker flags.
+func flagSymbols(flags []string) []string {
+	var symbols []string
+	for _, linkFlag := range flags {
+		// -X 'pkg.name=value' or -X pkg.name 'value' with go1.4 syntax
+		definition := strings.TrimLeft(strings.TrimPrefix(linkFlag, "-X "), `'"`)
+		if i := strings.IndexAny(definition, "= "); i >= 0 {
+			definition = definition[:i]
+		}
+		i := strings.LastIndex(definition, ".")
+		if i < 0 {
+			continue
+		}
+		symbols = append(symbols, symbolPrefix(definition[:i])+definition[i:])
+	}
+	return symbols
+}
+
+// symbolPrefix returns the prefix of the symbols of the package with import path pkg.
+// Like the go linker, it escapes dots in the last path element and special characters.
+func symbolPrefix(pkg string) string {
+	lastSlash := strings.LastIndex(pkg, "/")
+	var buf bytes.Buffer
+	for i := 0; i < len(pkg); i++ {
+		c := pkg[i]
+		if c <= ' ' || c == '%' || c == '"' || c >= 0x7f || (c == '.' && i > lastSlash) {
+			fmt.Fprintf(&buf, "%%%02x", c)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.String()
+}
+
+// missingSymbols returns the symbols not listed in nm, the output of `go tool nm`.
+func missingSymbols(nm string, symbols []string) []string {
+	defined := make(map[string]bool)
+	for _, line := range strings.Split(nm, "\n") {
+		// address, type and name of the symbol
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[len(fields)-2] != "U" {
+			defined[fields[len(fields)-1]] = true
+		}
+	}
+	var missing []string
+	for _, symbol := range symbols {
+		if !defined[symbol] {
+			missing = append(missing, symbol)
+		}
+	}
+	return missing
+}
+
+// splitVersionInfo populates the individual version components of info.
+// Versions that are not semver-compliant are left intact with a warning.
+func splitVersionInfo(info *version.Info) {
+	if info.Version == "" {
+		return
+	}
+	semver, err := version.ParseSemver(info.Version)
+	if err != nil {
+		warnf("not splitting version: %v", err)
+		return
+	}
+	info.VersionMajor = strconv.FormatInt(semver.Major, 10)
+	info.VersionMinor = strconv.FormatInt(semver.Minor, 10)
+	info.VersionPatch = strconv.FormatInt(semver.Patch, 10)
+}
+
+// getVersionInfo collects the build version information using the specified git tool.
+func getVersionInfo(git *git) (*version.Info, error) {
+	commitID, err := targetCommitID(git)
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain git commit ID: %v\n", err)
 	}
-	treeState, err := git.treeState()
-	if err != nil {
-		return nil, fmt.Errorf("failed to determine git tree state: %v\n", err)
+	// The working tree only describes the commit of -at-tag if the commit is checked out:
+	// otherwise the commit is clean and the branch and submodules are unknown.
+	checkedOut := true
+	if *atTag != "" {
+		headCommitID, err := git.commitID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain git commit ID: %v\n", err)
+		}
+		checkedOut = headCommitID == commitID
+	}
+	treeState := clean
+	var branch string
+	if checkedOut {
+		treeState, err = git.treeState(treeStateScope(*scope), *includeIgnored)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine git tree state: %v\n", err)
+		}
+		branch, err = git.branch()
+		if err != nil {
+			branch = ""
+		}
+	}
+	var treeHash string
+	if *includeTreeHash {
+		treeHash, err = git.treeHash(commitID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain git tree hash: %v\n", err)
+		}
+	}
+	var submodules []version.SubmoduleInfo
+	if *includeSubmodules && !checkedOut {
+		warnf("omitting submodules as tag %v is not checked out", *atTag)
+	} else if *includeSubmodules {
+		submodules, err = git.submodules()
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain git submodules: %v\n", err)
+		}
+	}
+	var commitTime string
+	if *includeCommitTime {
+		commitTime, err = git.commitTime(commitID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain git commit time: %v\n", err)
+		}
+	}
+	abbrevLength := *abbrev
+	if *abbrevUnique {
+		abbrevLength, err = git.uniqueAbbrev(commitID, abbrevLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine unique abbreviation length: %v\n", err)
+		}
+	}
+	strategy := *describeStrategy
+	if *merged {
+		strategy = strategySemverHighest
+	}
+	var tag string
+	if strategy == strategyGit {
+		tag, err = git.tag(commitID, abbrevLength, *long)
+	} else {
+		tag, err = git.semverTag(commitID, abbrevLength, strategy, *long)
 	}
-	tag, err := git.tag(commitID)
 	if err != nil {
 		tag = ""
 	}
-	if tag != "" {
-		tag = semverify(tag)
-		if treeState == dirty {
-			tag = tag + "-" + string(treeState)
+	if sha := parseDescribe(tag, commitID, abbrevLength, false).SHA; tag != "" && len(sha) != abbrevLength {
+		warnf("git abbreviated the commit ID to %v hexadecimal digits instead of the requested %v", len(sha), abbrevLength)
+	}
+	versionString := version.FromDescribe(tag, treeState == dirty).Version
+	if *versionTemplate != "" && tag != "" {
+		versionString, err = renderVersion(*versionTemplate, parseDescribe(tag, commitID, abbrevLength, treeState == dirty))
+		if err != nil {
+			return nil, fmt.Errorf("failed to render version template: %v\n", err)
+		}
+	}
+	versionString, err = version.SanitizeVersion(versionString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version: %v\n", err)
+	}
+	if *semverStrict {
+		if _, err = version.ParseSemver(versionString); err != nil {
+			return nil, fmt.Errorf("computed version is not a valid semantic version (disable with -semver-strict=false): %v\n", err)
 		}
 	}
 	return &version.Info{
-		Version:      tag,
-		GitCommit:    commitID,
-		GitTreeState: string(treeState),
+		Version:        versionString,
+		GitCommit:      commitID,
+		GitTreeState:   string(treeState),
+		Dirty:          treeState == dirty,
+		GitBranch:      branch,
+		SourceTreeHash: treeHash,
+		CommitTime:     commitTime,
+		Submodules:     submodules,
 	}, nil
 }
 
+// describeParts defines the parts of the `git describe` output
+// available to the version template.
+type describeParts struct {
+	// Tag is the most recent tag
+	Tag string
+	// Commits is the number of commits since Tag
+	Commits int
+	// SHA is the abbreviated commit ID
+	SHA string
+	// Dirty is true if the git tree has changes
+	Dirty bool
+}
+
+// describePattern matches the output of `git describe` for commits past the most recent tag.
+var describePattern = regexp.MustCompile(`^(.+)-([0-9]+)-g([0-9a-f]+)$`)
+
+// parseDescribe splits the output of `git describe` for commitID into parts.
+func parseDescribe(describe, commitID string, abbrev int, dirty bool) describeParts {
+	if match := describePattern.FindStringSubmatch(describe); match != nil {
+		return describeParts{Tag: match[1], Commits: mustAtoi(match[2]), SHA: match[3], Dirty: dirty}
+	}
+	// the commit is tagged
+	sha := commitID
+	if len(sha) > abbrev {
+		sha = sha[:abbrev]
+	}
+	return describeParts{Tag: describe, SHA: sha, Dirty: dirty}
+}
+
+// renderVersion computes the version from parts using the template text.
+func renderVersion(text string, parts describeParts) (string, error) {
+	tmpl, err := template.New("version").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, parts); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // goToolVersion determines the version of the `go tool`.
 func goToolVersion() (toolVersion, error) {
 	goTool := &tool.T{Cmd: "go"}
@@ -163,6 +1231,45 @@ func goToolVersion() (toolVersion, error) {
 	return toolVersionUnknown, nil
 }
 
+// goModulePath returns the path of the main module in directory dir.
+func goModulePath(dir string) (string, error) {
+	goTool := &tool.T{Cmd: "go", Args: []string{"-C", dir}}
+	return goTool.Exec("list", "-m")
+}
+
+// selectToolVersion returns the version of the go tool the linker flags are generated for:
+// the version given with -go-version, if any, or the detected one.
+// It warns if the override disagrees with the detected version as the linker flags
+// might use the wrong syntax for the go tool that actually builds the program.
+func selectToolVersion(override string, detected toolVersion) (toolVersion, error) {
+	if override == "" {
+		return detected, nil
+	}
+	result := parseToolVersion(override)
+	if result == toolVersionUnknown {
+		return toolVersionUnknown, fmt.Errorf("invalid go version %q: expected a version like go1.22", override)
+	}
+	if detected != toolVersionUnknown && detected != result {
+		warnf("-go-version %v disagrees with the version reported by `go version`: the linker flags might use the wrong syntax", override)
+	}
+	return result, nil
+}
+
+// goTargetPlatform determines the target operating system and architecture of the `go tool`.
+// It honors GOOS and GOARCH environment variables for cross-compilation.
+func goTargetPlatform() (goOS, goArch string, err error) {
+	goTool := &tool.T{Cmd: "go"}
+	out, err := goTool.Exec("env", "GOOS", "GOARCH")
+	if err != nil {
+		return "", "", err
+	}
+	env := strings.Fields(out)
+	if len(env) != 2 {
+		return "", "", fmt.Errorf("unexpected output of `go env`: %q", out)
+	}
+	return env[0], env[1], nil
+}
+
 // parseToolVersion translates a string version of the form 'go1.4.3' to a numeric value 14.
 func parseToolVersion(version string) toolVersion {
 	match := goVersionPattern.FindStringSubmatch(version)
@@ -175,8 +1282,8 @@ func parseToolVersion(version string) toolVersion {
 	return toolVersionUnknown
 }
 
-func newGit(pkg string) *git {
-	args := []string{"--work-tree", pkg, "--git-dir", filepath.Join(pkg, ".git")}
+func newGit(workTree, gitDir string) *git {
+	args := []string{"--work-tree", workTree, "--git-dir", gitDir}
 	return &git{&tool.T{
 		Cmd:  "git",
 		Args: args,
@@ -185,7 +1292,13 @@ func newGit(pkg string) *git {
 
 // git represents an instance of the git tool.
 type git struct {
-	*tool.T
+	runner
+}
+
+// runner executes a command with the given arguments and returns its output.
+// It is implemented by tool.T.
+type runner interface {
+	Exec(args ...string) (string, error)
 }
 
 // treeState describes the state of the git tree.
@@ -198,6 +1311,19 @@ const (
 	dirty           = "dirty"
 )
 
+// treeStateScope defines which changes are considered when determining the tree state.
+type treeStateScope string
+
+const (
+	// scopeWorktree only considers changes in the working tree that have not been staged,
+	// including untracked files
+	scopeWorktree treeStateScope = "worktree"
+	// scopeIndex only considers changes staged in the index
+	scopeIndex treeStateScope = "index"
+	// scopeBoth considers all changes
+	scopeBoth treeStateScope = "both"
+)
+
 // toolVersion represents a tool version as an integer.
 // toolVersion only considers the first two significant version parts and is computed as follows:
 // 	majorVersion*10+minorVersion
@@ -205,34 +1331,256 @@ type toolVersion int
 
 const toolVersionUnknown toolVersion = 0
 
+// Output formats
+const (
+	// formatFlags outputs linker flags for `go build -ldflags`
+	formatFlags = "flags"
+	// formatKo outputs linker flags as a YAML list for `ko`
+	formatKo = "ko"
+	// formatDescribe outputs the verbatim `git describe --tags --dirty --long` of HEAD
+	formatDescribe = "describe"
+	// formatSBOM outputs package information for a software bill of materials
+	formatSBOM = "sbom-fragment"
+	// formatProvenance outputs a provenance predicate stub
+	formatProvenance = "provenance"
+	// formatDotenv outputs environment variables in the .env file format of Docker Compose
+	formatDotenv = "dotenv"
+)
+
+// formats lists all supported output formats
+var formats = []string{formatFlags, formatKo, formatDescribe, formatSBOM, formatProvenance, formatDotenv}
+
+func validFormat(format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// Strategies to select the tag the version is based on
+const (
+	strategyGit           = "git"
+	strategySemverHighest = "semver-highest"
+	strategySemverNearest = "semver-nearest"
+)
+
+// encodingBase64 encodes the values of the linker flags with base64
+// so that they survive arbitrary quoting by shells and build tools
+const encodingBase64 = "base64"
+
+// encodedValuePrefix marks base64-encoded values for the version package
+const encodedValuePrefix = "b64:"
+
+// Limits for the length of an abbreviated commit ID
+const (
+	minAbbrev = 4
+	maxAbbrev = 40
+)
+
+// targetCommitID returns the ID of the commit the version is computed for:
+// the commit of the tag given with -at-tag or HEAD.
+func targetCommitID(git *git) (string, error) {
+	if *atTag != "" {
+		return git.tagCommitID(*atTag)
+	}
+	return git.commitID()
+}
+
 func (r *git) commitID() (string, error) {
 	return r.Exec("rev-parse", "HEAD^{commit}")
 }
 
-func (r *git) treeState() (treeState, error) {
-	out, err := r.Exec("status", "--porcelain")
+// tagCommitID returns the ID of the commit the specified tag points to.
+func (r *git) tagCommitID(tag string) (string, error) {
+	commitID, err := r.Exec("rev-parse", "--verify", "--quiet", "refs/tags/"+tag+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("tag %q does not exist", tag)
+	}
+	return commitID, nil
+}
+
+// commitTime returns the committer date of the specified commit in strict ISO 8601 format.
+func (r *git) commitTime(commitID string) (string, error) {
+	return r.Exec("log", "-1", "--format=%cI", commitID)
+}
+
+// treeHash returns the hash of the tree object of the specified commit.
+func (r *git) treeHash(commitID string) (string, error) {
+	return r.Exec("rev-parse", commitID+"^{tree}")
+}
+
+// treeState determines the state of the git tree considering changes in the given scope.
+// If includeIgnored is true, files ignored by git are considered changes to the working tree.
+func (r *git) treeState(scope treeStateScope, includeIgnored bool) (treeState, error) {
+	// The branch header line guarantees that the status columns of the first entry
+	// survive the whitespace trimming of the output
+	args := []string{"status", "--porcelain", "--branch"}
+	if includeIgnored {
+		args = append(args, "--ignored")
+	}
+	out, err := r.Exec(args...)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 2 || strings.HasPrefix(line, "##") {
+			continue
+		}
+		// The first column is the status of the index and the second - the status of the working tree
+		index, worktree := line[0], line[1]
+		if index == '?' || index == '!' {
+			// untracked or ignored file
+			index = ' '
+		}
+		if (scope != scopeWorktree && index != ' ') || (scope != scopeIndex && worktree != ' ') {
+			return dirty, nil
+		}
+	}
+	return clean, nil
+}
+
+// branch returns the name of the current branch or an empty string for a detached HEAD.
+func (r *git) branch() (string, error) {
+	out, err := r.Exec("rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if out == "HEAD" {
+		return "", nil
+	}
+	return out, nil
+}
+
+// tag describes the specified commit with `git describe --tags`.
+// If long is true, the number of commits and the abbreviated commit ID are included
+// even if the commit is tagged.
+func (r *git) tag(commitID string, abbrev int, long bool) (string, error) {
+	args := []string{"describe", "--tags", fmt.Sprintf("--abbrev=%d", abbrev)}
+	if long {
+		args = append(args, "--long")
+	}
+	return r.Exec(append(args, commitID+"^{commit}")...)
+}
+
+// semverTag describes the specified commit relative to the semver tag merged into it
+// that is selected by the given describe strategy (semver-highest or semver-nearest)
+// in the format of `git describe --tags`: `<tag>-<number of commits>-g<abbreviated commit ID>`
+// or just the tag if it points to the commit and long is false.
+// The number of commits is counted for the selected tag only with semver-highest
+// and for every semver tag with semver-nearest.
+func (r *git) semverTag(commitID string, abbrev int, strategy string, long bool) (string, error) {
+	out, err := r.Exec("for-each-ref", "--merged="+commitID, "--format=%(refname:lstrip=2)", "refs/tags")
+	if err != nil {
+		return "", err
+	}
+	var selected string
+	selectedCount := -1
+	for _, tag := range strings.Split(out, "\n") {
+		if _, err := version.ParseSemver(tag); err != nil {
+			continue
+		}
+		if strategy == strategySemverHighest {
+			if result, _ := version.Compare(tag, selected); selected == "" || result > 0 {
+				selected = tag
+			}
+			continue
+		}
+		count, err := r.countCommits(tag, commitID)
+		if err != nil {
+			return "", err
+		}
+		if selected != "" {
+			result, _ := version.Compare(tag, selected)
+			if count > selectedCount || (count == selectedCount && result <= 0) {
+				continue
+			}
+		}
+		selected, selectedCount = tag, count
+	}
+	if selected == "" {
+		return "", fmt.Errorf("no semver tags merged into commit %v", commitID)
+	}
+	if selectedCount < 0 {
+		if selectedCount, err = r.countCommits(selected, commitID); err != nil {
+			return "", err
+		}
+	}
+	if selectedCount == 0 && !long {
+		return selected, nil
+	}
+	short, err := r.Exec("rev-parse", fmt.Sprintf("--short=%d", abbrev), commitID)
 	if err != nil {
 		return "", err
 	}
-	if len(out) == 0 {
-		return clean, nil
+	return fmt.Sprintf("%s-%d-g%s", selected, selectedCount, short), nil
+}
+
+// countCommits returns the number of commits reachable from commitID but not from tag.
+func (r *git) countCommits(tag, commitID string) (int, error) {
+	out, err := r.Exec("rev-list", "--count", tag+".."+commitID)
+	if err != nil {
+		return 0, err
 	}
-	return dirty, nil
+	count, err := strconv.Atoi(out)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output of `git rev-list --count`: %q", out)
+	}
+	return count, nil
 }
 
-func (r *git) tag(commitID string) (string, error) {
-	return r.Exec("describe", "--tags", "--abbrev=14", commitID+"^{commit}")
+// submodules returns the paths and checked out commits of the git submodules.
+func (r *git) submodules() ([]version.SubmoduleInfo, error) {
+	out, err := r.Exec("submodule", "status")
+	if err != nil {
+		return nil, err
+	}
+	return parseSubmoduleStatus(out), nil
 }
 
-// semverify transforms the output of `git describe` to be semver-compliant.
-func semverify(version string) string {
-	match := semverPattern.FindStringSubmatch(version)
-	if match != nil && len(match) == 6 {
-		// replace the last component of the semver (which is always 0 in our versioning scheme)
-		// with the number of commits since the last tag
-		return fmt.Sprintf("%v.%v.%v+%v", match[1], match[2], match[4], match[5])
+// parseSubmoduleStatus parses the output of `git submodule status`.
+// Each line has the form `[ +-U]<commit> <path>[ (<describe>)]`.
+func parseSubmoduleStatus(out string) []version.SubmoduleInfo {
+	var submodules []version.SubmoduleInfo
+	for _, line := range strings.Split(out, "\n") {
+		// the status prefix of the first line might have been trimmed with whitespace
+		line = strings.TrimLeft(line, " +-U")
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			continue
+		}
+		commit, path := line[:i], strings.TrimSpace(line[i+1:])
+		if j := strings.LastIndex(path, " ("); j >= 0 && strings.HasSuffix(path, ")") {
+			path = path[:j]
+		}
+		submodules = append(submodules, version.SubmoduleInfo{Path: path, Commit: commit})
+	}
+	return submodules
+}
+
+// remoteURL returns the URL of the remote `origin`.
+func (r *git) remoteURL() (string, error) {
+	return r.Exec("config", "--get", "remote.origin.url")
+}
+
+// describe returns the output of `git describe` for HEAD in long format
+// with the dirty marker.
+func (r *git) describe(abbrev int) (string, error) {
+	return r.Exec("describe", "--tags", "--dirty", "--long", fmt.Sprintf("--abbrev=%d", abbrev))
+}
+
+// uniqueAbbrev returns the abbreviation length of at least abbrev characters
+// that uniquely identifies the commit commitID.
+func (r *git) uniqueAbbrev(commitID string, abbrev int) (int, error) {
+	out, err := r.Exec("rev-parse", fmt.Sprintf("--short=%d", abbrev), commitID)
+	if err != nil {
+		return 0, err
+	}
+	if len(out) > abbrev {
+		return len(out), nil
 	}
-	return version
+	return abbrev, nil
 }
 
 // mustAtoi converts value to an integer.
@@ -244,3 +1592,11 @@ func mustAtoi(value string) int {
 	}
 	return result
 }
+
+// warnf logs a non-fatal warning unless warnings are suppressed with -quiet.
+func warnf(format string, args ...interface{}) {
+	if *quiet {
+		return
+	}
+	log.Printf("warning: "+format, args...)
+}
@@ -0,0 +1,1527 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitational/version"
+	"github.com/gravitational/version/pkg/tool"
+)
+
+func TestSplitVersionFlags(t *testing.T) {
+	info := &version.Info{
+		Version:      "1.2.3-beta.1+0123456789abcd",
+		GitCommit:    "0123456789abcdef0123456789abcdef01234567",
+		GitTreeState: "clean",
+	}
+	splitVersionInfo(info)
+
+	flags := linkFlags(info, 15)
+	expected := []string{
+		"-X github.com/gravitational/version.gitCommit=0123456789abcdef0123456789abcdef01234567",
+		"-X github.com/gravitational/version.gitTreeState=clean",
+		"-X github.com/gravitational/version.version=1.2.3-beta.1+0123456789abcd",
+		"-X github.com/gravitational/version.versionMajor=1",
+		"-X github.com/gravitational/version.versionMinor=2",
+		"-X github.com/gravitational/version.versionPatch=3",
+	}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected %q but got %q", expected, flags)
+	}
+}
+
+func TestSplitVersionSkipsInvalidSemver(t *testing.T) {
+	info := &version.Info{Version: "some-branch-name"}
+	splitVersionInfo(info)
+
+	flags := linkFlags(info, 15)
+	expected := []string{"-X github.com/gravitational/version.version=some-branch-name"}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected %q but got %q", expected, flags)
+	}
+}
+
+func TestTreeStateScope(t *testing.T) {
+	const (
+		stagedOnly   = "## master\nM  staged.go"
+		worktreeOnly = "## master\n M modified.go\n?? untracked.go"
+		cleanTree    = "## master"
+	)
+	var testCases = []struct {
+		status   string
+		scope    treeStateScope
+		expected treeState
+	}{
+		{stagedOnly, scopeIndex, dirty},
+		{stagedOnly, scopeWorktree, clean},
+		{stagedOnly, scopeBoth, dirty},
+		{worktreeOnly, scopeIndex, clean},
+		{worktreeOnly, scopeWorktree, dirty},
+		{worktreeOnly, scopeBoth, dirty},
+		{cleanTree, scopeIndex, clean},
+		{cleanTree, scopeWorktree, clean},
+		{cleanTree, scopeBoth, clean},
+		{"## master\nMM both.go", scopeIndex, dirty},
+		{"## master\nMM both.go", scopeWorktree, dirty},
+	}
+	for _, testCase := range testCases {
+		git := &git{fakeRunner{"status --porcelain --branch": testCase.status}}
+		state, err := git.treeState(testCase.scope, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != testCase.expected {
+			t.Fatalf("expected tree state %v for scope %v and status %q but got %v",
+				testCase.expected, testCase.scope, testCase.status, state)
+		}
+	}
+}
+
+// fakeRunner is a runner that replies with canned output for known command lines.
+type fakeRunner map[string]string
+
+func (r fakeRunner) Exec(args ...string) (string, error) {
+	command := strings.Join(args, " ")
+	out, ok := r[command]
+	if !ok {
+		return "", fmt.Errorf("unexpected command `%s`", command)
+	}
+	return out, nil
+}
+
+func TestBranch(t *testing.T) {
+	tool := &git{fakeRunner{"rev-parse --abbrev-ref HEAD": "feature/foo"}}
+	branch, err := tool.branch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "feature/foo" {
+		t.Fatalf("expected branch feature/foo but got %q", branch)
+	}
+
+	detached := &git{fakeRunner{"rev-parse --abbrev-ref HEAD": "HEAD"}}
+	branch, err = detached.branch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "" {
+		t.Fatalf("expected no branch for detached HEAD but got %q", branch)
+	}
+}
+
+func TestUniqueAbbrev(t *testing.T) {
+	const commitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+	// git extends the abbreviated commit ID if the requested length is ambiguous
+	git := &git{fakeRunner{
+		"rev-parse --short=4 " + commitID: "2032d5b",
+		"rev-parse --short=8 " + commitID: "2032d5b1",
+	}}
+
+	abbrev, err := git.uniqueAbbrev(commitID, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abbrev != 7 {
+		t.Fatalf("expected abbreviation length to be bumped to 7 but got %v", abbrev)
+	}
+
+	abbrev, err = git.uniqueAbbrev(commitID, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if abbrev != 8 {
+		t.Fatalf("expected abbreviation length 8 to be kept but got %v", abbrev)
+	}
+}
+
+func TestPlatformFlags(t *testing.T) {
+	info := &version.Info{Version: "1.0.0", GoOS: "linux", GoArch: "arm64"}
+	flags := linkFlags(info, 15)
+	expected := []string{
+		"-X github.com/gravitational/version.version=1.0.0",
+		"-X github.com/gravitational/version.goOS=linux",
+		"-X github.com/gravitational/version.goArch=arm64",
+	}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected %q but got %q", expected, flags)
+	}
+}
+
+func TestTreeHash(t *testing.T) {
+	defer func(value bool) { *includeTreeHash = value }(*includeTreeHash)
+	*includeTreeHash = true
+
+	const treeHash = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	info, err := getVersionInfo(newFakeGit(fakeRunner{
+		"rev-parse " + testCommitID + "^{tree}": treeHash,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SourceTreeHash != treeHash {
+		t.Fatalf("expected tree hash %v but got %q", treeHash, info.SourceTreeHash)
+	}
+
+	expected := "-X github.com/gravitational/version.sourceTreeHash=" + treeHash
+	if !containsFlag(linkFlags(info, 15), expected) {
+		t.Fatalf("expected flag %q in %q", expected, linkFlags(info, 15))
+	}
+}
+
+// testCommitID is the commit ID reported by the git tool returned by newFakeGit.
+const testCommitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+
+// newFakeGit returns a git tool describing a clean checkout of branch master
+// three commits past tag v1.2.0. responses add to or override the default replies.
+func newFakeGit(responses fakeRunner) *git {
+	runner := fakeRunner{
+		"rev-parse HEAD^{commit}":                                   testCommitID,
+		"status --porcelain --branch":                               "## master",
+		"rev-parse --abbrev-ref HEAD":                               "master",
+		"describe --tags --abbrev=14 " + testCommitID + "^{commit}": "v1.2.0-3-g2032d5b1a2b3c4",
+	}
+	for command, out := range responses {
+		runner[command] = out
+	}
+	return &git{runner}
+}
+
+func containsFlag(flags []string, flag string) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTreeStateIncludeIgnored(t *testing.T) {
+	git := &git{fakeRunner{
+		"status --porcelain --branch":           "## master",
+		"status --porcelain --branch --ignored": "## master\n!! build/artifact.o",
+	}}
+	var testCases = []struct {
+		scope          treeStateScope
+		includeIgnored bool
+		expected       treeState
+	}{
+		{scopeBoth, false, clean},
+		{scopeBoth, true, dirty},
+		{scopeWorktree, true, dirty},
+		{scopeIndex, true, clean},
+	}
+	for _, testCase := range testCases {
+		state, err := git.treeState(testCase.scope, testCase.includeIgnored)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state != testCase.expected {
+			t.Fatalf("expected tree state %v for scope %v (include ignored: %v) but got %v",
+				testCase.expected, testCase.scope, testCase.includeIgnored, state)
+		}
+	}
+}
+
+func TestFormatKo(t *testing.T) {
+	flags := []string{
+		"-X github.com/gravitational/version.gitCommit=2032d5b",
+		"-X github.com/gravitational/version.version=1.2.3+2032d5b",
+	}
+	expected := `- "-X github.com/gravitational/version.gitCommit=2032d5b"
+- "-X github.com/gravitational/version.version=1.2.3+2032d5b"
+`
+	if result := formatLinkFlags(flags, formatKo); result != expected {
+		t.Fatalf("expected:\n%s\nbut got:\n%s", expected, result)
+	}
+
+	expected = strings.Join(flags, " ")
+	if result := formatLinkFlags(flags, formatFlags); result != expected {
+		t.Fatalf("expected %q but got %q", expected, result)
+	}
+}
+
+func TestAtTag(t *testing.T) {
+	defer func(value string) { *atTag = value }(*atTag)
+
+	dir := newTestRepo(t)
+	runGit(t, dir, "commit", "--allow-empty", "-m", "Release")
+	runGit(t, dir, "tag", "v1.0.0")
+	taggedCommitID := runGit(t, dir, "rev-parse", "HEAD")
+	runGit(t, dir, "commit", "--allow-empty", "-m", "Next")
+
+	*atTag = "v1.0.0"
+	info, err := getVersionInfo(newGit(dir, filepath.Join(dir, ".git")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GitCommit != taggedCommitID {
+		t.Fatalf("expected commit %v but got %v", taggedCommitID, info.GitCommit)
+	}
+	if info.Version != "v1.0.0" {
+		t.Fatalf("expected version v1.0.0 but got %v", info.Version)
+	}
+	if info.GitTreeState != string(clean) || info.GitBranch != "" {
+		t.Fatalf("expected a clean tree and no branch for a tag that is not checked out but got %v and %q",
+			info.GitTreeState, info.GitBranch)
+	}
+
+	// the state of the working tree applies to the checked out tag
+	runGit(t, dir, "checkout", "--quiet", "v1.0.0")
+	if err = os.WriteFile(filepath.Join(dir, "untracked"), []byte("dirty"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err = getVersionInfo(newGit(dir, filepath.Join(dir, ".git")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GitTreeState != string(dirty) {
+		t.Fatalf("expected a dirty tree for the checked out tag but got %v", info.GitTreeState)
+	}
+
+	*atTag = "v2.0.0"
+	if _, err = getVersionInfo(newGit(dir, filepath.Join(dir, ".git"))); err == nil {
+		t.Fatal("expected an error for a missing tag")
+	}
+}
+
+// newTestRepo creates a new git repository with an initial commit in a temporary directory.
+func newTestRepo(t *testing.T) string {
+	dir := t.TempDir()
+	runGit(t, dir, "init")
+	runGit(t, dir, "commit", "--allow-empty", "-m", "Initial commit")
+	return dir
+}
+
+// runGit executes git with args in the repository dir and returns its output.
+func runGit(t *testing.T, dir string, args ...string) string {
+	git := &tool.T{
+		Cmd:  "git",
+		Args: []string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@localhost", "-c", "commit.gpgsign=false"},
+	}
+	out, err := git.Exec(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestDescribePassthrough(t *testing.T) {
+	const raw = "v1.2.0-3-g2032d5b1a2b3c4-dirty"
+	git := &git{fakeRunner{"describe --tags --dirty --long --abbrev=14": raw}}
+	describe, err := git.describe(14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if describe != raw {
+		t.Fatalf("expected raw describe output %q but got %q", raw, describe)
+	}
+}
+
+func TestDescribeConflicts(t *testing.T) {
+	defer func(value string) { *format = value }(*format)
+	defer func(value string) { *atTag = value }(*atTag)
+	defer func(value string) { *describeStrategy = value }(*describeStrategy)
+	defer func(value bool) { *merged = value }(*merged)
+	defer func(value bool) { *abbrevUnique = value }(*abbrevUnique)
+
+	var testCases = []struct {
+		set      func()
+		expected string
+	}{
+		{func() { *atTag = "v1.2.0" }, "-at-tag conflicts"},
+		{func() { *describeStrategy = strategySemverHighest }, "-describe-strategy=semver-highest conflicts"},
+		{func() { *merged = true }, "-merged conflicts"},
+		{func() { *abbrevUnique = true }, "-abbrev-unique conflicts"},
+	}
+	for _, testCase := range testCases {
+		*format, *atTag, *describeStrategy, *merged, *abbrevUnique = formatDescribe, "", strategyGit, false, false
+		testCase.set()
+		if err := run(); err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Fatalf("expected an error containing %q but got %v", testCase.expected, err)
+		}
+	}
+}
+
+func TestCommitTime(t *testing.T) {
+	defer func(value bool) { *includeCommitTime = value }(*includeCommitTime)
+	*includeCommitTime = true
+
+	const commitTime = "2024-06-01T12:30:00+02:00"
+	info, err := getVersionInfo(newFakeGit(fakeRunner{
+		"log -1 --format=%cI " + testCommitID: commitTime,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "-X github.com/gravitational/version.commitTime=" + commitTime
+	if !containsFlag(linkFlags(info, 15), expected) {
+		t.Fatalf("expected flag %q in %q", expected, linkFlags(info, 15))
+	}
+}
+
+func TestSBOMFragment(t *testing.T) {
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID}
+	payload, err := sbomFragment("mytool", info, "git@github.com:gravitational/version.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	if err = json.Unmarshal(payload, &fields); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"name":             "mytool",
+		"versionInfo":      "1.2.3",
+		"downloadLocation": "git+ssh://git@github.com/gravitational/version.git@" + testCommitID,
+		"sourceInfo":       "built from git commit " + testCommitID,
+	}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("expected %v but got %v", expected, fields)
+	}
+
+	payload, err = sbomFragment("mytool", &version.Info{GitCommit: testCommitID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = json.Unmarshal(payload, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["downloadLocation"] != spdxNoAssertion || fields["versionInfo"] != spdxNoAssertion {
+		t.Fatalf("expected unknown fields to be NOASSERTION but got %v", fields)
+	}
+}
+
+func TestQuiet(t *testing.T) {
+	defer func(value bool) { *quiet = value }(*quiet)
+	defer func(value string) { *scope = value }(*scope)
+	defer log.SetOutput(os.Stderr)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	*quiet = false
+	splitVersionInfo(&version.Info{Version: "not-semver"})
+	if buf.Len() == 0 {
+		t.Fatal("expected a warning")
+	}
+
+	buf.Reset()
+	*quiet = true
+	splitVersionInfo(&version.Info{Version: "not-semver"})
+	if buf.Len() != 0 {
+		t.Fatalf("expected warnings to be suppressed but got %q", buf.String())
+	}
+
+	*scope = "bogus"
+	if err := run(); err == nil {
+		t.Fatal("expected errors to surface with -quiet")
+	}
+}
+
+func TestWriteDotfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".version")
+	info := &version.Info{Version: "1.2.3+2032d5b1a2b3c4", GitCommit: testCommitID}
+
+	if err := writeDotfile(path, info, false); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContents(t, path, "1.2.3+2032d5b1a2b3c4\n")
+
+	if err := writeDotfile(path, info, true); err != nil {
+		t.Fatal(err)
+	}
+	assertFileContents(t, path, "1.2.3+2032d5b1a2b3c4\n"+testCommitID+"\n")
+}
+
+func assertFileContents(t *testing.T, path, expected string) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != expected {
+		t.Fatalf("expected contents %q but got %q", expected, contents)
+	}
+}
+
+func TestBuildInfo(t *testing.T) {
+	now := time.Date(2024, time.June, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	var info version.Info
+	if err := setBuildInfo(&info, "", now); err != nil {
+		t.Fatal(err)
+	}
+	if info.BuildTime != "2024-06-01T10:30:00Z" || info.BuildTimeSource != version.BuildTimeSourceClock {
+		t.Fatalf("expected wall clock build time but got %v (%v)", info.BuildTime, info.BuildTimeSource)
+	}
+
+	if err := setBuildInfo(&info, "1700000000", now); err != nil {
+		t.Fatal(err)
+	}
+	if info.BuildTime != "2023-11-14T22:13:20Z" || info.BuildTimeSource != version.BuildTimeSourceEpoch {
+		t.Fatalf("expected build time from SOURCE_DATE_EPOCH but got %v (%v)", info.BuildTime, info.BuildTimeSource)
+	}
+
+	if err := setBuildInfo(&info, "yesterday", now); err == nil {
+		t.Fatal("expected an error for invalid SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestSeparateGitDir(t *testing.T) {
+	workTree := t.TempDir()
+	gitDir := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, workTree, "init", "--separate-git-dir", gitDir)
+	runGit(t, workTree, "commit", "--allow-empty", "-m", "Initial commit")
+	commitID := runGit(t, workTree, "rev-parse", "HEAD")
+	// remove the link to the repository so that it can only be found with the explicit git dir
+	if err := os.Remove(filepath.Join(workTree, ".git")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := getVersionInfo(newGit(workTree, gitDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.GitCommit != commitID {
+		t.Fatalf("expected commit %v but got %v", commitID, info.GitCommit)
+	}
+	if info.GitTreeState != string(clean) {
+		t.Fatalf("expected clean tree state but got %v", info.GitTreeState)
+	}
+}
+
+func TestVersionTemplate(t *testing.T) {
+	defer func(value string) { *versionTemplate = value }(*versionTemplate)
+
+	var testCases = []struct {
+		template string
+		describe string
+		dirty    bool
+		expected string
+	}{
+		{
+			template: "{{.Tag}}-{{.Commits}}-{{.SHA}}",
+			describe: "v1.2.0-3-g2032d5b1a2b3c4",
+			expected: "v1.2.0-3-2032d5b1a2b3c4",
+		},
+		{
+			template: `{{.Tag}}{{if .Commits}}.dev{{.Commits}}{{end}}{{if .Dirty}}+dirty{{end}}`,
+			describe: "v1.2.0-3-g2032d5b1a2b3c4",
+			dirty:    true,
+			expected: "v1.2.0.dev3+dirty",
+		},
+		{
+			template: `{{.Tag}}{{if .Commits}}.dev{{.Commits}}{{end}}+{{.SHA}}`,
+			describe: "v1.2.0",
+			expected: "v1.2.0+2032d5b1a2b3c4",
+		},
+	}
+	for _, testCase := range testCases {
+		*versionTemplate = testCase.template
+		status := "## master"
+		if testCase.dirty {
+			status += "\n M main.go"
+		}
+		info, err := getVersionInfo(newFakeGit(fakeRunner{
+			"status --porcelain --branch":                               status,
+			"describe --tags --abbrev=14 " + testCommitID + "^{commit}": testCase.describe,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != testCase.expected {
+			t.Fatalf("expected version %q for template %q but got %q", testCase.expected, testCase.template, info.Version)
+		}
+	}
+}
+
+func TestAbbrevMismatch(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	// git describe used fewer hexadecimal digits than requested
+	info, err := getVersionInfo(newFakeGit(fakeRunner{
+		"describe --tags --abbrev=14 " + testCommitID + "^{commit}": "v1.2.0-3-g2032d5b",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "1.2.3+2032d5b" {
+		t.Fatalf("expected version 1.2.3+2032d5b but got %v", info.Version)
+	}
+	if !strings.Contains(buf.String(), "7 hexadecimal digits instead of the requested 14") {
+		t.Fatalf("expected a warning about the abbreviation length but got %q", buf.String())
+	}
+
+	buf.Reset()
+	if _, err = getVersionInfo(newFakeGit(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warnings but got %q", buf.String())
+	}
+}
+
+func TestSubmodules(t *testing.T) {
+	defer func(value bool) { *includeSubmodules = value }(*includeSubmodules)
+	*includeSubmodules = true
+
+	// the leading space of the first line is trimmed from the command output
+	const status = `3f2a1b9c0d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a vendor/lib (v1.0.0-2-g3f2a1b9)
++8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3f2a1b9c0d third party/other (heads/main)
+-0a9b8c7d6e5f4a3f2a1b9c0d8e7f6a5b4c3d2e1f uninitialized`
+	info, err := getVersionInfo(newFakeGit(fakeRunner{"submodule status": status}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []version.SubmoduleInfo{
+		{Path: "vendor/lib", Commit: "3f2a1b9c0d8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a"},
+		{Path: "third party/other", Commit: "8e7f6a5b4c3d2e1f0a9b8c7d6e5f4a3f2a1b9c0d"},
+		{Path: "uninitialized", Commit: "0a9b8c7d6e5f4a3f2a1b9c0d8e7f6a5b4c3d2e1f"},
+	}
+	if !reflect.DeepEqual(info.Submodules, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, info.Submodules)
+	}
+
+	// the value contains whitespace and has to be quoted
+	payload, _ := json.Marshal(expected)
+	flag := "-X 'github.com/gravitational/version.gitSubmodules=" + string(payload) + "'"
+	if !containsFlag(linkFlags(info, 15), flag) {
+		t.Fatalf("expected flag %q in %q", flag, linkFlags(info, 15))
+	}
+}
+
+func TestBuildState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	args := []string{"-pkg", "github.com/example/app"}
+	goVersion := parseToolVersion("go1.22")
+
+	cached, err := readBuildState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != nil {
+		t.Fatalf("expected no state for a missing state file but got %+v", cached)
+	}
+
+	state, err := currentBuildState(newFakeGit(nil), goVersion, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.Flags = "-X github.com/gravitational/version.version=1.2.3"
+	if err = writeBuildState(path, *state); err != nil {
+		t.Fatal(err)
+	}
+	cached, err = readBuildState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*cached, *state) {
+		t.Fatalf("expected state %+v but got %+v", *state, *cached)
+	}
+
+	var testCases = []struct {
+		comment   string
+		responses fakeRunner
+		goVersion toolVersion
+		args      []string
+		expected  bool
+	}{
+		{comment: "unchanged", goVersion: goVersion, args: args, expected: true},
+		{comment: "new commit", responses: fakeRunner{"rev-parse HEAD^{commit}": "a1b2c3d4e5f60718293a4b5c6d7e8f9012345678"}, goVersion: goVersion, args: args},
+		{comment: "dirty tree", responses: fakeRunner{"status --porcelain --branch": "## master\n M main.go"}, goVersion: goVersion, args: args},
+		{comment: "different arguments", goVersion: goVersion, args: append(args, "-compat")},
+		{comment: "different go version", goVersion: parseToolVersion("go1.4"), args: args},
+	}
+	for _, testCase := range testCases {
+		current, err := currentBuildState(newFakeGit(testCase.responses), testCase.goVersion, testCase.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := cached.unchanged(*current); result != testCase.expected {
+			t.Fatalf("%v: expected unchanged to be %v but got %v", testCase.comment, testCase.expected, result)
+		}
+	}
+}
+
+func TestEditionFlags(t *testing.T) {
+	defer func(value string) { *edition = value }(*edition)
+
+	info := &version.Info{Version: "1.0.0", Edition: version.EditionEnterprise}
+	expected := "-X github.com/gravitational/version.edition=enterprise"
+	if flags := linkFlags(info, 15); !containsFlag(flags, expected) {
+		t.Fatalf("expected %q in %q", expected, flags)
+	}
+
+	*edition = "premium"
+	if err := run(); err == nil || !strings.Contains(err.Error(), "invalid edition") {
+		t.Fatalf("expected an invalid edition error but got %v", err)
+	}
+}
+
+func TestCgoSymbol(t *testing.T) {
+	defer func(value string) { *cgoSymbol = value }(*cgoSymbol)
+
+	*cgoSymbol = "app_version"
+	if err := run(); err != errCgoSymbol {
+		t.Fatalf("expected %q but got %v", errCgoSymbol, err)
+	}
+}
+
+func TestMerged(t *testing.T) {
+	defer func(value bool) { *merged = value }(*merged)
+
+	// The side branch carries the higher tag v1.1.0 while the nearest tag
+	// in terms of the number of commits is v1.0.1 on the main branch
+	dir := newTestRepo(t)
+	// git describe only counts the commits across merges exactly
+	// if the commits have distinct dates
+	date := time.Now()
+	commit := func(args ...string) {
+		date = date.Add(time.Minute)
+		t.Setenv("GIT_AUTHOR_DATE", date.Format(time.RFC3339))
+		t.Setenv("GIT_COMMITTER_DATE", date.Format(time.RFC3339))
+		runGit(t, dir, args...)
+	}
+	mainBranch := runGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	runGit(t, dir, "checkout", "-b", "side")
+	commit("commit", "--allow-empty", "-m", "Feature")
+	runGit(t, dir, "tag", "v1.1.0")
+	runGit(t, dir, "checkout", mainBranch)
+	for _, message := range []string{"Fix 1", "Fix 2", "Fix 3"} {
+		commit("commit", "--allow-empty", "-m", message)
+	}
+	runGit(t, dir, "tag", "v1.0.1")
+	commit("merge", "--no-ff", "-m", "Merge side", "side")
+	commitID := runGit(t, dir, "rev-parse", "HEAD")
+	git := newGit(dir, filepath.Join(dir, ".git"))
+
+	var testCases = []struct {
+		merged   bool
+		expected string
+	}{
+		{merged: false, expected: "1.0.2+" + commitID[:14]},
+		{merged: true, expected: "1.1.4+" + commitID[:14]},
+	}
+	for _, testCase := range testCases {
+		*merged = testCase.merged
+		info, err := getVersionInfo(git)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != testCase.expected {
+			t.Fatalf("expected version %v with -merged=%v but got %v", testCase.expected, testCase.merged, info.Version)
+		}
+	}
+
+	runGit(t, dir, "tag", "v1.2.0")
+	*merged = true
+	info, err := getVersionInfo(git)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Version != "v1.2.0" {
+		t.Fatalf("expected version v1.2.0 for a tagged commit but got %v", info.Version)
+	}
+}
+
+func TestSemverHighestCommandCount(t *testing.T) {
+	dir := newTestRepo(t)
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.10.0", "not-semver"} {
+		runGit(t, dir, "commit", "--allow-empty", "-m", tag)
+		runGit(t, dir, "tag", tag)
+	}
+	runGit(t, dir, "commit", "--allow-empty", "-m", "Next")
+	commitID := runGit(t, dir, "rev-parse", "HEAD")
+
+	runner := &countingRunner{runner: newGit(dir, filepath.Join(dir, ".git")).runner}
+	tag, err := (&git{runner}).semverTag(commitID, 7, strategySemverHighest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "v1.10.0-2-g" + commitID[:7]; tag != expected {
+		t.Fatalf("expected %v but got %v", expected, tag)
+	}
+	if runner.counts["rev-list"] != 1 {
+		t.Fatalf("expected a single `git rev-list` for the selected tag but got %v", runner.counts["rev-list"])
+	}
+}
+
+// countingRunner counts the commands executed by runner.
+type countingRunner struct {
+	runner
+	counts map[string]int
+}
+
+func (r *countingRunner) Exec(args ...string) (string, error) {
+	if r.counts == nil {
+		r.counts = make(map[string]int)
+	}
+	r.counts[args[0]]++
+	return r.runner.Exec(args...)
+}
+
+func TestProvenance(t *testing.T) {
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID}
+	payload, err := provenancePredicate(info, "git@github.com:gravitational/version.git", "ci", "https://ci.example.com/jobs/42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var predicate map[string]interface{}
+	if err = json.Unmarshal(payload, &predicate); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"builder":   map[string]interface{}{"id": "ci"},
+		"buildType": provenanceBuildType,
+		"metadata":  map[string]interface{}{"buildInvocationId": "https://ci.example.com/jobs/42"},
+		"materials": []interface{}{
+			map[string]interface{}{
+				"uri":    "git+ssh://git@github.com/gravitational/version.git",
+				"digest": map[string]interface{}{"sha1": testCommitID},
+			},
+		},
+	}
+	if !reflect.DeepEqual(predicate, expected) {
+		t.Fatalf("expected %v but got %v", expected, predicate)
+	}
+}
+
+func TestFlagSymbols(t *testing.T) {
+	defer func(value string) { *versionPackage = value }(*versionPackage)
+	defer func(value bool) { *compatMode = value }(*compatMode)
+
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID, GitTreeState: "clean", BuildUser: "John Doe"}
+	expected := []string{
+		"gopkg.in/version%2ev1.gitCommit",
+		"gopkg.in/version%2ev1.gitTreeState",
+		"gopkg.in/version%2ev1.version",
+		"gopkg.in/version%2ev1.buildUser",
+	}
+	*versionPackage = "gopkg.in/version.v1"
+	for _, compat := range []bool{false, true} {
+		*compatMode = compat
+		if symbols := flagSymbols(linkFlags(info, 15)); !reflect.DeepEqual(symbols, expected) {
+			t.Fatalf("expected %q with -compat=%v but got %q", expected, compat, symbols)
+		}
+	}
+}
+
+func TestMissingSymbols(t *testing.T) {
+	nm := `  6d98a0 D github.com/gravitational/version.gitBranch
+  6d9880 D github.com/gravitational/version.gitCommit
+  55d200 R github.com/gravitational/version.gitCommit.str
+         U github.com/gravitational/version.gitTreeState
+  6d9870 D github.com/gravitational/version.version`
+	symbols := []string{
+		"github.com/gravitational/version.gitCommit",
+		"github.com/gravitational/version.gitTreeState",
+		"github.com/gravitational/version.version",
+		"github.com/gravitational/version.buildHost",
+	}
+	expected := []string{
+		"github.com/gravitational/version.gitTreeState",
+		"github.com/gravitational/version.buildHost",
+	}
+	if missing := missingSymbols(nm, symbols); !reflect.DeepEqual(missing, expected) {
+		t.Fatalf("expected missing symbols %q but got %q", expected, missing)
+	}
+	if missing := missingSymbols(nm, symbols[:1]); len(missing) != 0 {
+		t.Fatalf("expected no missing symbols but got %q", missing)
+	}
+}
+
+func TestTargetsManifest(t *testing.T) {
+	platforms, err := parseTargets("linux/amd64, darwin/arm64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID, GitTreeState: "clean"}
+	payload, err := targetsManifest(info, platforms, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result manifest
+	if err = json.Unmarshal(payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	common := "-X github.com/gravitational/version.gitCommit=" + testCommitID +
+		" -X github.com/gravitational/version.gitTreeState=clean" +
+		" -X github.com/gravitational/version.version=1.2.3"
+	expected := manifest{
+		Version:   "1.2.3",
+		GitCommit: testCommitID,
+		Targets: []manifestTarget{
+			{
+				GoOS:   "linux",
+				GoArch: "amd64",
+				LDFlags: common + " -X github.com/gravitational/version.goOS=linux" +
+					" -X github.com/gravitational/version.goArch=amd64",
+			},
+			{
+				GoOS:   "darwin",
+				GoArch: "arm64",
+				LDFlags: common + " -X github.com/gravitational/version.goOS=darwin" +
+					" -X github.com/gravitational/version.goArch=arm64",
+			},
+		},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, result)
+	}
+	if info.GoOS != "" {
+		t.Fatalf("expected the version information to remain unchanged but got %+v", info)
+	}
+
+	for _, value := range []string{"linux", "linux/amd64,", "/amd64", "linux/amd64/v3"} {
+		if _, err = parseTargets(value); err == nil {
+			t.Fatalf("expected an error for targets %q", value)
+		}
+	}
+}
+
+func TestEncodeBase64(t *testing.T) {
+	defer func(value string) { *encode = value }(*encode)
+
+	*encode = encodingBase64
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID, BuildUser: `John "Doe" $USER`}
+	flags := linkFlags(info, 15)
+	expected := "-X github.com/gravitational/version.buildUser=b64:" + base64.StdEncoding.EncodeToString([]byte(info.BuildUser))
+	if !containsFlag(flags, expected) {
+		t.Fatalf("expected %q in %q", expected, flags)
+	}
+	for _, linkFlag := range flags {
+		if value := linkFlag[strings.Index(linkFlag, "=")+1:]; strings.ContainsAny(value, `"'$ `) {
+			t.Fatalf("expected an encoded value without special characters but got %q", linkFlag)
+		}
+	}
+}
+
+func TestDescribeStrategy(t *testing.T) {
+	defer func(value string) { *describeStrategy = value }(*describeStrategy)
+
+	// Tags by number of commits between them and HEAD:
+	//  - staging (not a semver tag): 2 commits
+	//  - v1.0.1: 3 commits
+	//  - v1.1.0 on a merged side branch: 5 commits
+	dir := newTestRepo(t)
+	// git describe only counts the commits across merges exactly
+	// if the commits have distinct dates
+	date := time.Now()
+	commit := func(args ...string) {
+		date = date.Add(time.Minute)
+		t.Setenv("GIT_AUTHOR_DATE", date.Format(time.RFC3339))
+		t.Setenv("GIT_COMMITTER_DATE", date.Format(time.RFC3339))
+		runGit(t, dir, args...)
+	}
+	mainBranch := runGit(t, dir, "rev-parse", "--abbrev-ref", "HEAD")
+	runGit(t, dir, "checkout", "-b", "side")
+	commit("commit", "--allow-empty", "-m", "Feature")
+	runGit(t, dir, "tag", "v1.1.0")
+	runGit(t, dir, "checkout", mainBranch)
+	for _, message := range []string{"Fix 1", "Fix 2", "Fix 3"} {
+		commit("commit", "--allow-empty", "-m", message)
+	}
+	runGit(t, dir, "tag", "v1.0.1")
+	commit("commit", "--allow-empty", "-m", "Deploy")
+	runGit(t, dir, "tag", "staging")
+	commit("merge", "--no-ff", "-m", "Merge side", "side")
+	commitID := runGit(t, dir, "rev-parse", "HEAD")
+	git := newGit(dir, filepath.Join(dir, ".git"))
+
+	var testCases = []struct {
+		strategy string
+		expected string
+	}{
+		{strategy: strategyGit, expected: "staging-2-g" + commitID[:14]},
+		{strategy: strategySemverNearest, expected: "1.0.3+" + commitID[:14]},
+		{strategy: strategySemverHighest, expected: "1.1.5+" + commitID[:14]},
+	}
+	for _, testCase := range testCases {
+		*describeStrategy = testCase.strategy
+		info, err := getVersionInfo(git)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != testCase.expected {
+			t.Fatalf("expected version %v with strategy %v but got %v", testCase.expected, testCase.strategy, info.Version)
+		}
+	}
+}
+
+func TestSemverStrict(t *testing.T) {
+	defer func(value bool) { *semverStrict = value }(*semverStrict)
+
+	var testCases = []struct {
+		describe string
+		valid    bool
+	}{
+		{describe: "v1.2.0-3-g2032d5b1a2b3c4", valid: true},
+		{describe: "v1.2.0", valid: true},
+		{describe: "release-2024-3-g2032d5b1a2b3c4", valid: false},
+		{describe: "v1.2-3-g2032d5b1a2b3c4", valid: false},
+	}
+	for _, testCase := range testCases {
+		git := newFakeGit(fakeRunner{"describe --tags --abbrev=14 " + testCommitID + "^{commit}": testCase.describe})
+
+		*semverStrict = false
+		info, err := getVersionInfo(git)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version == "" {
+			t.Fatalf("expected a version for %q", testCase.describe)
+		}
+
+		*semverStrict = true
+		_, err = getVersionInfo(git)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("expected %q to be accepted: %v but got %v", testCase.describe, testCase.valid, err)
+		}
+	}
+}
+
+func TestCheckVendor(t *testing.T) {
+	defer log.SetOutput(os.Stderr)
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+
+	dir := t.TempDir()
+	const versionPkg = "github.com/gravitational/version"
+	checkVendor(dir, versionPkg)
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning without a vendor directory but got %q", buf.String())
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "vendor", filepath.FromSlash(versionPkg)), 0755); err != nil {
+		t.Fatal(err)
+	}
+	checkVendor(dir, versionPkg)
+	if !strings.Contains(buf.String(), "vendored") || !strings.Contains(buf.String(), "import path "+versionPkg) {
+		t.Fatalf("expected a warning confirming the import path but got %q", buf.String())
+	}
+
+	buf.Reset()
+	checkVendor(dir, "example.com/app/vendor/"+versionPkg)
+	if !strings.Contains(buf.String(), "use the import path "+versionPkg+" instead") {
+		t.Fatalf("expected a warning about the vendor import path but got %q", buf.String())
+	}
+}
+
+func TestBanner(t *testing.T) {
+	defer func(value bool) { *showBanner = value }(*showBanner)
+	defer func(value string) { *pkg = value }(*pkg)
+	defer func(value string) { *workTree = value }(*workTree)
+	defer func(value string) { *gitDir = value }(*gitDir)
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+	defer log.SetOutput(os.Stderr)
+
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+	commitID := runGit(t, dir, "rev-parse", "HEAD")
+
+	var stderr bytes.Buffer
+	log.SetOutput(&stderr)
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = writer
+
+	*showBanner = true
+	*pkg, *workTree, *gitDir = dir, "", ""
+	err = run()
+	writer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := fmt.Sprintf("version v1.2.0 (commit %v)\n", commitID)
+	if stderr.String() != expected {
+		t.Fatalf("expected banner %q on stderr but got %q", expected, stderr.String())
+	}
+	if !strings.HasPrefix(string(stdout), "-X ") || strings.Contains(string(stdout), "version v1.2.0 (") {
+		t.Fatalf("expected only linker flags on stdout but got %q", stdout)
+	}
+
+	info := &version.Info{Version: "1.2.3+2032d5b-dirty", GitCommit: "2032d5b", GitTreeState: "dirty"}
+	if result := banner(info); result != "version 1.2.3+2032d5b-dirty (commit 2032d5b, dirty)" {
+		t.Fatalf("unexpected banner %q", result)
+	}
+}
+
+func TestModulePathFlag(t *testing.T) {
+	info := &version.Info{Version: "1.2.3", ModulePath: "github.com/example/app"}
+	expected := "-X github.com/gravitational/version.modulePath=github.com/example/app"
+	if flags := linkFlags(info, 15); !containsFlag(flags, expected) {
+		t.Fatalf("expected %q in %q", expected, flags)
+	}
+}
+
+func TestDotenv(t *testing.T) {
+	info := &version.Info{Version: "1.2.3+2032d5b-dirty", GitCommit: testCommitID, GitTreeState: "dirty"}
+	expected := "VERSION=1.2.3+2032d5b-dirty\n" +
+		"GIT_COMMIT=" + testCommitID + "\n" +
+		"GIT_TREE_STATE=dirty\n" +
+		"GIT_BRANCH=\n"
+	if result := dotenv(info); result != expected {
+		t.Fatalf("expected %q but got %q", expected, result)
+	}
+
+	var testCases = []struct {
+		value    string
+		expected string
+	}{
+		{"feature/dotenv", "feature/dotenv"},
+		{"", ""},
+		{"new ui", "'new ui'"},
+		{"release #2", "'release #2'"},
+		{"cost-${HOME}", "'cost-${HOME}'"},
+		{`it's "done"`, `"it's \"done\""`},
+		{`it's $HOME\n`, `"it's \$HOME\\n"`},
+		{"it's\nmultiline", `"it's\nmultiline"`},
+	}
+	for _, testCase := range testCases {
+		if result := dotenvQuote(testCase.value); result != testCase.expected {
+			t.Fatalf("expected %v for %q but got %v", testCase.expected, testCase.value, result)
+		}
+	}
+}
+
+func TestMinimal(t *testing.T) {
+	info := &version.Info{
+		Version:      "1.2.3+2032d5b1a2b3c4",
+		GitCommit:    testCommitID,
+		GitTreeState: "clean",
+		GitBranch:    "master",
+		BuildTime:    "2024-06-01T10:30:00Z",
+		GoOS:         "linux",
+		GoArch:       "amd64",
+	}
+	expected := []string{"-X github.com/gravitational/version.version=1.2.3+2032d5b1a2b3c4"}
+	if flags := linkFlags(minimalInfo(info), 15); !reflect.DeepEqual(flags, expected) {
+		t.Fatalf("expected %q but got %q", expected, flags)
+	}
+}
+
+func TestSanitizeVersion(t *testing.T) {
+	defer func(value string) { *versionTemplate = value }(*versionTemplate)
+
+	*versionTemplate = "{{.Tag}}-édition"
+	if _, err := getVersionInfo(newFakeGit(nil)); err == nil || !strings.Contains(err.Error(), "invalid version") {
+		t.Fatalf("expected an invalid version error but got %v", err)
+	}
+}
+
+func TestLong(t *testing.T) {
+	defer func(value bool) { *long = value }(*long)
+	defer func(value string) { *describeStrategy = value }(*describeStrategy)
+
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+	commitID := runGit(t, dir, "rev-parse", "HEAD")
+	git := newGit(dir, filepath.Join(dir, ".git"))
+
+	var testCases = []struct {
+		long     bool
+		strategy string
+		expected string
+	}{
+		{long: false, strategy: strategyGit, expected: "v1.2.0"},
+		{long: true, strategy: strategyGit, expected: "1.2.0+" + commitID[:14]},
+		{long: false, strategy: strategySemverHighest, expected: "v1.2.0"},
+		{long: true, strategy: strategySemverHighest, expected: "1.2.0+" + commitID[:14]},
+	}
+	for _, testCase := range testCases {
+		*long, *describeStrategy = testCase.long, testCase.strategy
+		info, err := getVersionInfo(git)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Version != testCase.expected {
+			t.Fatalf("expected version %v with -long=%v and strategy %v but got %v",
+				testCase.expected, testCase.long, testCase.strategy, info.Version)
+		}
+	}
+
+	// a tag with a non-zero patch keeps its patch
+	runGit(t, dir, "commit", "--allow-empty", "-m", "Patch release")
+	runGit(t, dir, "tag", "v1.2.5")
+	commitID = runGit(t, dir, "rev-parse", "HEAD")
+	*long = true
+	for _, strategy := range []string{strategyGit, strategySemverHighest} {
+		*describeStrategy = strategy
+		info, err := getVersionInfo(git)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := "1.2.5+" + commitID[:14]; info.Version != expected {
+			t.Fatalf("expected version %v with -long and strategy %v but got %v", expected, strategy, info.Version)
+		}
+	}
+}
+
+func TestTreeDirtyFlag(t *testing.T) {
+	defer func(value bool) { *includeTreeDirty = value }(*includeTreeDirty)
+
+	var testCases = []struct {
+		status   string
+		expected string
+	}{
+		{status: "## master", expected: "clean"},
+		{status: "## master\n M main.go", expected: "dirty"},
+	}
+	for _, testCase := range testCases {
+		info, err := getVersionInfo(newFakeGit(fakeRunner{"status --porcelain --branch": testCase.status}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Dirty != (testCase.expected == "dirty") {
+			t.Fatalf("expected Dirty to agree with tree state %v but got %v", testCase.expected, info.Dirty)
+		}
+		state := "-X github.com/gravitational/version.gitTreeState=" + testCase.expected
+		treeDirty := fmt.Sprintf("-X github.com/gravitational/version.gitTreeDirty=%v", info.Dirty)
+		*includeTreeDirty = false
+		if flags := linkFlags(info, 15); !containsFlag(flags, state) || containsFlag(flags, treeDirty) {
+			t.Fatalf("expected %q without %q in %q", state, treeDirty, flags)
+		}
+		*includeTreeDirty = true
+		if flags := linkFlags(info, 15); !containsFlag(flags, state) || !containsFlag(flags, treeDirty) {
+			t.Fatalf("expected %q and %q in %q", state, treeDirty, flags)
+		}
+	}
+}
+
+func TestOnlyIfNewer(t *testing.T) {
+	defer func(value string) { *onlyIfNewer = value }(*onlyIfNewer)
+
+	var testCases = []struct {
+		comment  string
+		baseline string
+		expected bool
+	}{
+		{comment: "older baseline", baseline: "v1.1.0", expected: true},
+		{comment: "older prerelease baseline", baseline: "v1.2.0-rc.1", expected: true},
+		{comment: "equal baseline", baseline: "v1.2.0"},
+		{comment: "newer baseline", baseline: "v1.2.1"},
+	}
+	for _, testCase := range testCases {
+		result, err := newer("1.2.0+2032d5b1a2b3c4", testCase.baseline)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("%v: expected newer to be %v but got %v", testCase.comment, testCase.expected, result)
+		}
+	}
+
+	defer func(value string) { *pkg = value }(*pkg)
+	defer func(value string) { *workTree = value }(*workTree)
+	defer func(value string) { *gitDir = value }(*gitDir)
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+	*pkg, *workTree, *gitDir = dir, "", ""
+	*onlyIfNewer = "v1.2.0"
+	if err := run(); err != errNotNewer {
+		t.Fatalf("expected %q for a version equal to the baseline but got %v", errNotNewer, err)
+	}
+
+	*onlyIfNewer = "latest"
+	if err := run(); err == nil || !strings.Contains(err.Error(), "invalid baseline version") {
+		t.Fatalf("expected an invalid baseline version error but got %v", err)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	attrs := make(attrFlag)
+	for _, attr := range []string{"tracking-id=42", "jira_ticket=OPS-1234", "note=a=b c"} {
+		if err := attrs.Set(attr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, attr := range []string{"tracking-id", "=42", "1st=value", "bad key=value", "quote\"=value", "note=line\nbreak"} {
+		if err := attrs.Set(attr); err == nil {
+			t.Fatalf("expected an error for attribute %q", attr)
+		}
+	}
+
+	info := &version.Info{Version: "1.2.3", Attrs: attrs}
+	expected := `-X 'github.com/gravitational/version.buildAttrs={"jira_ticket":"OPS-1234","note":"a=b c","tracking-id":"42"}'`
+	flags := linkFlags(info, 15)
+	if !containsFlag(flags, expected) {
+		t.Fatalf("expected %q in %q", expected, flags)
+	}
+
+	// Read the attributes back as the version package would from the linker flag value
+	var decoded map[string]string
+	value := strings.TrimSuffix(strings.SplitN(expected, "=", 2)[1], "'")
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, map[string]string(attrs)) {
+		t.Fatalf("expected attributes %v but got %v", attrs, decoded)
+	}
+}
+
+func TestSymbolPrefix(t *testing.T) {
+	defer func(value string) { *varPrefix = value }(*varPrefix)
+	defer func(value string) { *versionPackage = value }(*versionPackage)
+
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID, GitTreeState: "clean"}
+	*versionPackage = "github.com/my/package/buildinfo"
+	*varPrefix = "myapp_"
+	expected := []string{
+		"github.com/my/package/buildinfo.myapp_gitCommit",
+		"github.com/my/package/buildinfo.myapp_gitTreeState",
+		"github.com/my/package/buildinfo.myapp_version",
+	}
+	if symbols := flagSymbols(linkFlags(info, 15)); !reflect.DeepEqual(symbols, expected) {
+		t.Fatalf("expected %q but got %q", expected, symbols)
+	}
+
+	*varPrefix = "my-app"
+	if err := run(); err == nil || !strings.Contains(err.Error(), "invalid symbol prefix") {
+		t.Fatalf("expected an invalid symbol prefix error but got %v", err)
+	}
+}
+
+func TestSelectToolVersion(t *testing.T) {
+	defer func(value bool) { *quiet = value }(*quiet)
+	defer log.SetOutput(os.Stderr)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	*quiet = false
+
+	var testCases = []struct {
+		comment  string
+		override string
+		detected toolVersion
+		expected toolVersion
+		warning  bool
+	}{
+		{comment: "no override", detected: parseToolVersion("go1.22.1"), expected: parseToolVersion("go1.22.1")},
+		{comment: "matching override", override: "go1.22", detected: parseToolVersion("go1.22.1"), expected: parseToolVersion("go1.22")},
+		{comment: "stale override", override: "go1.4", detected: parseToolVersion("go1.22.1"), expected: 14, warning: true},
+		{comment: "newer override", override: "go1.23", detected: parseToolVersion("go1.4.3"), expected: parseToolVersion("go1.23"), warning: true},
+		{comment: "undetected version", override: "go1.22", detected: toolVersionUnknown, expected: parseToolVersion("go1.22")},
+	}
+	for _, testCase := range testCases {
+		buf.Reset()
+		result, err := selectToolVersion(testCase.override, testCase.detected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("%v: expected version %v but got %v", testCase.comment, testCase.expected, result)
+		}
+		if warned := strings.Contains(buf.String(), "disagrees"); warned != testCase.warning {
+			t.Fatalf("%v: expected a warning: %v but got %q", testCase.comment, testCase.warning, buf.String())
+		}
+	}
+
+	if _, err := selectToolVersion("latest", parseToolVersion("go1.22.1")); err == nil {
+		t.Fatal("expected an error for an invalid go version")
+	}
+}
+
+func TestOutputFD(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "linkflags")
+	goTool := &tool.T{Cmd: "go"}
+	if _, err := goTool.Exec("build", "-o", binary, "."); err != nil {
+		t.Fatal(err)
+	}
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the first extra file is inherited as file descriptor 3
+	cmd := exec.Command(binary, "-pkg", dir, "-fd", "3")
+	cmd.ExtraFiles = []*os.File{w}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	err = cmd.Run()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(output), "github.com/gravitational/version.version=v1.2.0") {
+		t.Fatalf("expected linker flags written to the file descriptor but got %q", output)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no output on stdout but got %q", stdout.String())
+	}
+
+	// the read end of the pipe is not writable
+	cmd = exec.Command(binary, "-pkg", dir, "-fd", "3")
+	cmd.ExtraFiles = []*os.File{r}
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "not writable") {
+		t.Fatalf("expected an error for a file descriptor not open for writing but got %v: %s", err, out)
+	}
+
+	// errors are reported after the output to stderr
+	cmd = exec.Command(binary, "-pkg", filepath.Join(dir, "missing"), "-fd", "2")
+	if out, err := cmd.CombinedOutput(); err == nil || !strings.Contains(string(out), "invalid git directory") {
+		t.Fatalf("expected an error on stderr used for the output but got %v: %s", err, out)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	var testCases = []struct {
+		arg      string
+		expected string
+		ok       bool
+	}{
+		{arg: "pkg.version=1.2.0", expected: "pkg.version=1.2.0", ok: true},
+		{arg: `pkg.buildAttrs={"note":"value"}`, expected: `pkg.buildAttrs={"note":"value"}`, ok: true},
+		{arg: "pkg.buildUser=John Doe", expected: "'pkg.buildUser=John Doe'", ok: true},
+		{arg: "pkg.buildUser=John O'Brien", expected: `"pkg.buildUser=John O'Brien"`, ok: true},
+		{arg: `'quoted'`, expected: `"'quoted'"`, ok: true},
+		{arg: `pkg.buildUser=John "JD" O'Brien`},
+	}
+	for _, testCase := range testCases {
+		result, ok := quoteArg(testCase.arg)
+		if result != testCase.expected || ok != testCase.ok {
+			t.Fatalf("expected %q (%v) for %q but got %q (%v)", testCase.expected, testCase.ok, testCase.arg, result, ok)
+		}
+	}
+}
+
+func TestLinkFlagsBuild(t *testing.T) {
+	const (
+		testPackage = "github.com/gravitational/version/test"
+		buildUser   = `John "JD" O'Brien`
+	)
+	attrs := map[string]string{"note": "it's a b", "ticket": `"OPS-1234"`}
+	info := &version.Info{Version: "1.2.3", GitCommit: testCommitID, GitTreeState: "clean", BuildUser: buildUser, Attrs: attrs}
+	flags := linkFlags(info, parseToolVersion(runtime.Version()))
+
+	binary := filepath.Join(t.TempDir(), "test")
+	goTool := &tool.T{Cmd: "go"}
+	if _, err := goTool.Exec("build", "-o", binary, "-ldflags", formatLinkFlags(flags, formatFlags), testPackage); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := exec.Command(binary).CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var built version.Info
+	if err = json.Unmarshal(payload, &built); err != nil {
+		t.Fatal(err)
+	}
+	if built.BuildUser != buildUser {
+		t.Fatalf("expected build user %q but got %q", buildUser, built.BuildUser)
+	}
+	if !reflect.DeepEqual(built.Attrs, attrs) {
+		t.Fatalf("expected attributes %v but got %v", attrs, built.Attrs)
+	}
+}
+
+func TestStateFileSideEffects(t *testing.T) {
+	defer func(value string) { *pkg = value }(*pkg)
+	defer func(value string) { *workTree = value }(*workTree)
+	defer func(value string) { *gitDir = value }(*gitDir)
+	defer func(value string) { *stateFile = value }(*stateFile)
+	defer func(value string) { *dotfile = value }(*dotfile)
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+	tempDir := t.TempDir()
+	*pkg, *workTree, *gitDir = dir, "", ""
+	*stateFile = filepath.Join(tempDir, "state.json")
+	*dotfile = filepath.Join(tempDir, "VERSION")
+
+	for i := 0; i < 2; i++ {
+		os.Remove(*dotfile)
+		if err := run(); err != nil {
+			t.Fatalf("run %v: %v", i+1, err)
+		}
+		assertFileContents(t, *dotfile, "v1.2.0\n")
+	}
+}
+
+func TestStateFileUncachedFlags(t *testing.T) {
+	defer func(value string) { *pkg = value }(*pkg)
+	defer func(value string) { *workTree = value }(*workTree)
+	defer func(value string) { *gitDir = value }(*gitDir)
+	defer func(value string) { *stateFile = value }(*stateFile)
+	defer func(value string) { *verifySymbols = value }(*verifySymbols)
+	defer func(value bool) { *includePlatform = value }(*includePlatform)
+	defer func(stdout *os.File) { os.Stdout = stdout }(os.Stdout)
+
+	dir := newTestRepo(t)
+	runGit(t, dir, "tag", "v1.2.0")
+	tempDir := t.TempDir()
+	*pkg, *workTree, *gitDir = dir, "", ""
+	*stateFile = filepath.Join(tempDir, "state.json")
+
+	// runTool runs the tool and returns its output
+	runTool := func() (string, error) {
+		output, err := os.Create(filepath.Join(tempDir, "output"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer output.Close()
+		os.Stdout = output
+		if err = run(); err != nil && err != errUnchanged {
+			return "", err
+		}
+		contents, err := os.ReadFile(output.Name())
+		return string(contents), err
+	}
+
+	// cache the flags
+	if _, err := runTool(); err != nil {
+		t.Fatal(err)
+	}
+	*verifySymbols = filepath.Join(tempDir, "missing")
+	if _, err := runTool(); err == nil {
+		t.Fatal("expected -verify-symbols to verify a missing binary instead of using the cached flags")
+	}
+	*verifySymbols = ""
+
+	*includePlatform = true
+	for _, goos := range []string{"linux", "windows"} {
+		t.Setenv("GOOS", goos)
+		output, err := runTool()
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := "version.goOS=" + goos
+		if !strings.Contains(output, expected) {
+			t.Fatalf("expected %q in %q", expected, output)
+		}
+	}
+}
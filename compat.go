@@ -0,0 +1,225 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import "fmt"
+
+// RequireAtLeast panics if the current build version is below minimum.
+//
+// It is meant to be called once during startup (e.g. from `init` of an embedded plugin)
+// to guard against running inside an incompatible host and should not be used
+// on regular code paths.
+// Builds without version information (e.g. `go run` or `go test`) satisfy any valid minimum.
+// Note that this includes production binaries built without version information,
+// e.g. from a repository without tags, which silently skip the guard.
+func RequireAtLeast(minimum string) {
+	if skipMinimumCheck(minimum) {
+		return
+	}
+	current := Get().Version
+	result, err := Compare(current, minimum)
+	if err != nil {
+		panic(fmt.Sprintf("failed to compare version %q to required minimum %q: %v", current, minimum, err))
+	}
+	if result < 0 {
+		panic(fmt.Sprintf("version %v is below the required minimum %v", current, minimum))
+	}
+}
+
+// AssertMinVersion panics if the current build version is below minimum, the version
+// required by feature, with a message like `feature requires version >= minimum, running X`.
+//
+// Unlike RequireAtLeast, it is meant to guard individual code paths of a library
+// that require a newer host program.
+// Builds without version information satisfy any valid minimum as for RequireAtLeast.
+func AssertMinVersion(feature, minimum string) {
+	if skipMinimumCheck(minimum) {
+		return
+	}
+	current := Get().Version
+	result, err := Compare(current, minimum)
+	if err != nil {
+		panic(fmt.Sprintf("%v requires version >= %v, running %v: %v", feature, minimum, current, err))
+	}
+	if result < 0 {
+		panic(fmt.Sprintf("%v requires version >= %v, running %v", feature, minimum, current))
+	}
+}
+
+// skipMinimumCheck determines if the check of the minimum version is to be skipped
+// as the build has no version information to compare: only if minimum is valid
+// so that invalid minimums are caught in development builds as well.
+func skipMinimumCheck(minimum string) bool {
+	if _, err := ParseSemver(minimum); err != nil {
+		return false
+	}
+	return Get().unversioned()
+}
+
+// APICompatible determines if version available is API-compatible with version required
+// following the caret semantics of semver: available must have the same major version
+// and must not be lower than required.
+// Versions with major version 0 are considered unstable and every minor version is treated
+// as a breaking change.
+func APICompatible(required, available string) (bool, error) {
+	requiredVersion, err := ParseSemver(required)
+	if err != nil {
+		return false, err
+	}
+	availableVersion, err := ParseSemver(available)
+	if err != nil {
+		return false, err
+	}
+	return apiCompatible(*requiredVersion, *availableVersion), nil
+}
+
+// apiCompatible implements APICompatible for parsed versions.
+func apiCompatible(required, available Semver) bool {
+	if required.Major != available.Major {
+		return false
+	}
+	if required.Major == 0 && required.Minor != available.Minor {
+		return false
+	}
+	return available.Compare(required) >= 0
+}
+
+// UpdateAvailable determines if version latest is an update to the version of this build.
+// Prereleases are only considered updates if this build is a prerelease itself.
+func (r Info) UpdateAvailable(latest string) (bool, error) {
+	current, err := ParseSemver(r.Version)
+	if err != nil {
+		return false, err
+	}
+	latestVersion, err := ParseSemver(latest)
+	if err != nil {
+		return false, err
+	}
+	if latestVersion.Prerelease != "" && current.Prerelease == "" {
+		return false, nil
+	}
+	return latestVersion.Compare(*current) > 0, nil
+}
+
+// IsSupported determines if the version of this build is within the support window:
+// at or above version minimumSupported. It can be used to warn users of end-of-life versions.
+func (r Info) IsSupported(minimumSupported string) (bool, error) {
+	result, err := Compare(r.Version, minimumSupported)
+	if err != nil {
+		return false, err
+	}
+	return result >= 0, nil
+}
+
+// WithinPatches determines if the version of this build is at most n patch releases
+// below version latest on the same minor release line.
+// Versions on different major or minor release lines and versions newer than latest
+// are never within range.
+func (r Info) WithinPatches(latest string, n int) (bool, error) {
+	if n < 0 {
+		return false, fmt.Errorf("invalid number of patch releases %v", n)
+	}
+	current, err := ParseSemver(r.Version)
+	if err != nil {
+		return false, err
+	}
+	latestVersion, err := ParseSemver(latest)
+	if err != nil {
+		return false, err
+	}
+	if current.Major != latestVersion.Major || current.Minor != latestVersion.Minor {
+		return false, nil
+	}
+	if current.Compare(*latestVersion) > 0 {
+		return false, nil
+	}
+	return latestVersion.Patch-current.Patch <= int64(n), nil
+}
+
+// CanUpgrade determines if a rolling upgrade from version from to version to is allowed.
+// Upgrades must not skip minor releases:
+//   - any version of the same minor release line (e.g. 1.2.0 to 1.2.5) is allowed
+//   - the next minor release line of the same major version (e.g. 1.2.5 to 1.3.1) is allowed
+//   - the first minor release line of the next major version (e.g. 1.9.2 to 2.0.3) is allowed
+//
+// The last minor release line of a major version is not known, hence an upgrade to the next
+// major version is allowed from any minor release line and may skip minor releases
+// of the old major version (e.g. 1.2.0 to 2.0.0 skips 1.3 and later).
+// Downgrades and all other upgrades are rejected with an error.
+func CanUpgrade(from, to string) error {
+	fromVersion, err := ParseSemver(from)
+	if err != nil {
+		return err
+	}
+	toVersion, err := ParseSemver(to)
+	if err != nil {
+		return err
+	}
+	if toVersion.Compare(*fromVersion) < 0 {
+		return fmt.Errorf("downgrade from %v to %v is not allowed", from, to)
+	}
+	switch {
+	case toVersion.Major == fromVersion.Major && toVersion.Minor <= fromVersion.Minor+1:
+		return nil
+	case toVersion.Major == fromVersion.Major+1 && toVersion.Minor == 0:
+		return nil
+	case toVersion.Major == fromVersion.Major:
+		return fmt.Errorf("upgrade from %v to %v skips minor releases: upgrade to %v.%v first",
+			from, to, fromVersion.Major, fromVersion.Minor+1)
+	}
+	return fmt.Errorf("upgrade from %v to %v is not allowed: upgrade to %v.0 first", from, to, fromVersion.Major+1)
+}
+
+// PluginCompatible returns an error if a plugin built against host version pluginBuiltAgainst
+// cannot be loaded by a host of version hostVersion.
+// The plugin is compatible if the host has the same major version and a minor version
+// not lower than the one the plugin has been built against: hosts only add to the plugin API
+// within a major version.
+func PluginCompatible(hostVersion, pluginBuiltAgainst string) error {
+	host, err := ParseSemver(hostVersion)
+	if err != nil {
+		return err
+	}
+	plugin, err := ParseSemver(pluginBuiltAgainst)
+	if err != nil {
+		return err
+	}
+	if host.Major != plugin.Major {
+		return fmt.Errorf("plugin built against version %v is incompatible with host version %v: major versions differ",
+			pluginBuiltAgainst, hostVersion)
+	}
+	if host.Minor < plugin.Minor {
+		return fmt.Errorf("plugin built against version %v requires host version %v.%v or newer, running %v",
+			pluginBuiltAgainst, plugin.Major, plugin.Minor, hostVersion)
+	}
+	return nil
+}
+
+// SafeReload determines if a process running build old can hot-reload build new:
+// both versions must have the same major and minor version while the patch version,
+// prerelease and build metadata may differ. Builds with versions that are not
+// semver-compliant always require a full restart.
+func SafeReload(old, new Info) bool {
+	oldVersion, err := ParseSemver(old.Version)
+	if err != nil {
+		return false
+	}
+	newVersion, err := ParseSemver(new.Version)
+	if err != nil {
+		return false
+	}
+	return oldVersion.Major == newVersion.Major && oldVersion.Minor == newVersion.Minor
+}
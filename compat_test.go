@@ -0,0 +1,291 @@
+package version
+
+import "testing"
+
+func TestRequireAtLeast(t *testing.T) {
+	defer saveVars()()
+
+	Set(Info{Version: "v1.2.3"})
+	for _, minimum := range []string{"1.2.3", "v1.2.0", "1.2.3-beta.1", "0.9.0"} {
+		if err := catchPanic(func() { RequireAtLeast(minimum) }); err != nil {
+			t.Fatalf("expected %v to satisfy minimum %v but got `%v`", Get().Version, minimum, err)
+		}
+	}
+
+	for _, minimum := range []string{"1.2.4", "v2.0.0", "invalid"} {
+		if err := catchPanic(func() { RequireAtLeast(minimum) }); err == nil {
+			t.Fatalf("expected %v to fail minimum %v", Get().Version, minimum)
+		}
+	}
+
+	resetVars()
+	if err := catchPanic(func() { RequireAtLeast("99.0.0") }); err != nil {
+		t.Fatalf("expected a build without version information to satisfy any minimum but got `%v`", err)
+	}
+	if err := catchPanic(func() { RequireAtLeast("invalid") }); err == nil {
+		t.Fatal("expected a panic for an invalid minimum version without version information")
+	}
+}
+
+func TestAssertMinVersion(t *testing.T) {
+	defer saveVars()()
+
+	Set(Info{Version: "v1.2.3"})
+	if err := catchPanic(func() { AssertMinVersion("streaming", "1.2.0") }); err != nil {
+		t.Fatalf("expected %v to satisfy minimum 1.2.0 but got `%v`", Get().Version, err)
+	}
+
+	err := catchPanic(func() { AssertMinVersion("streaming", "1.3.0") })
+	expected := "streaming requires version >= 1.3.0, running v1.2.3"
+	if err != expected {
+		t.Fatalf("expected panic %q but got %v", expected, err)
+	}
+
+	if err = catchPanic(func() { AssertMinVersion("streaming", "invalid") }); err == nil {
+		t.Fatal("expected a panic for an invalid minimum version")
+	}
+
+	resetVars()
+	if err = catchPanic(func() { AssertMinVersion("streaming", "99.0.0") }); err != nil {
+		t.Fatalf("expected a build without version information to satisfy any minimum but got `%v`", err)
+	}
+}
+
+// catchPanic runs fn and returns the value it panicked with, if any.
+func catchPanic(fn func()) (err interface{}) {
+	defer func() {
+		err = recover()
+	}()
+	fn()
+	return nil
+}
+
+func TestAPICompatible(t *testing.T) {
+	var testCases = []struct {
+		required  string
+		available string
+		expected  bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.5.3", true},
+		{"v1.2.3", "v1.2.4", true},
+		{"1.2.3", "1.2.2", false},
+		{"1.2.0", "1.1.9", false},
+		{"1.2.0", "2.0.0", false},
+		{"2.0.0", "1.9.0", false},
+		{"1.2.0", "1.2.0-rc.1", false},
+		{"0.3.0", "0.3.5", true},
+		{"0.3.1", "0.3.0", false},
+		{"0.3.0", "0.4.0", false},
+		{"0.3.0", "1.0.0", false},
+	}
+	for _, testCase := range testCases {
+		result, err := APICompatible(testCase.required, testCase.available)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %v for required %v and available %v but got %v",
+				testCase.expected, testCase.required, testCase.available, result)
+		}
+	}
+
+	for _, versions := range [][2]string{{"1.2", "1.2.0"}, {"1.2.0", "latest"}} {
+		if _, err := APICompatible(versions[0], versions[1]); err == nil {
+			t.Fatalf("expected an error for required %v and available %v", versions[0], versions[1])
+		}
+	}
+}
+
+func TestUpdateAvailable(t *testing.T) {
+	var testCases = []struct {
+		current  string
+		latest   string
+		expected bool
+	}{
+		{"1.2.3", "1.2.4", true},
+		{"v1.2.3", "v2.0.0", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.2.3", "1.2.2", false},
+		{"1.2.3+abc", "1.2.3+def", false},
+		{"1.2.3", "1.3.0-beta.1", false},
+		{"1.3.0-alpha.1", "1.3.0-beta.1", true},
+		{"1.3.0-beta.1", "1.3.0", true},
+		{"1.3.0-beta.2", "1.3.0-beta.1", false},
+	}
+	for _, testCase := range testCases {
+		result, err := Info{Version: testCase.current}.UpdateAvailable(testCase.latest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %v for %v -> %v but got %v", testCase.expected, testCase.current, testCase.latest, result)
+		}
+	}
+
+	if _, err := (Info{Version: "1.2.3"}).UpdateAvailable("latest"); err == nil {
+		t.Fatal("expected an error for an invalid latest version")
+	}
+	if _, err := (Info{}).UpdateAvailable("1.2.3"); err == nil {
+		t.Fatal("expected an error for a build without version")
+	}
+}
+
+func TestIsSupported(t *testing.T) {
+	var testCases = []struct {
+		current  string
+		minimum  string
+		expected bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"v2.0.1", "1.9.0", true},
+		{"1.3.0+2032d5b", "1.3.0", true},
+		{"1.1.9", "1.2.0", false},
+		{"1.2.0-rc.1", "1.2.0", false},
+	}
+	for _, testCase := range testCases {
+		result, err := Info{Version: testCase.current}.IsSupported(testCase.minimum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %v for %v with minimum %v but got %v", testCase.expected, testCase.current, testCase.minimum, result)
+		}
+	}
+
+	if _, err := (Info{Version: "1.2.3"}).IsSupported("1.2"); err == nil {
+		t.Fatal("expected an error for an invalid minimum version")
+	}
+	if _, err := (Info{}).IsSupported("1.2.3"); err == nil {
+		t.Fatal("expected an error for a build without version")
+	}
+}
+
+func TestWithinPatches(t *testing.T) {
+	var testCases = []struct {
+		current  string
+		latest   string
+		n        int
+		expected bool
+	}{
+		{"1.2.3", "1.2.3", 0, true},
+		{"1.2.3", "1.2.5", 2, true},
+		{"v1.2.3", "v1.2.6", 2, false},
+		{"1.2.3-rc.1", "1.2.3", 0, true},
+		// newer than latest
+		{"1.2.5", "1.2.3", 0, false},
+		{"1.2.5", "1.2.3", 5, false},
+		{"1.2.3+2032d5b", "1.2.4", 1, true},
+		{"1.2.3", "1.3.0", 5, false},
+		{"1.9.9", "2.9.9", 5, false},
+	}
+	for _, testCase := range testCases {
+		result, err := Info{Version: testCase.current}.WithinPatches(testCase.latest, testCase.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %v for %v within %v patches of %v but got %v",
+				testCase.expected, testCase.current, testCase.n, testCase.latest, result)
+		}
+	}
+
+	if _, err := (Info{Version: "1.2.3"}).WithinPatches("1.2", 1); err == nil {
+		t.Fatal("expected an error for an invalid latest version")
+	}
+	if _, err := (Info{Version: "1.2.3"}).WithinPatches("1.2.4", -1); err == nil {
+		t.Fatal("expected an error for a negative number of patch releases")
+	}
+}
+
+func TestCanUpgrade(t *testing.T) {
+	var testCases = []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.2.5", true},
+		{"1.2.5", "1.3.1", true},
+		{"v1.2.5", "v1.3.0-rc.1", true},
+		{"1.9.2", "2.0.3", true},
+		// major upgrades may skip minor releases of the old major version
+		{"1.2.0", "2.0.0", true},
+		// skipped minor releases
+		{"1.2.5", "1.4.0", false},
+		{"1.9.2", "2.1.0", false},
+		// major jumps
+		{"1.2.5", "3.0.0", false},
+		// downgrades
+		{"1.3.0", "1.2.9", false},
+		{"2.0.0", "1.9.0", false},
+	}
+	for _, testCase := range testCases {
+		err := CanUpgrade(testCase.from, testCase.to)
+		if (err == nil) != testCase.allowed {
+			t.Fatalf("expected upgrade from %v to %v to be allowed: %v but got %v", testCase.from, testCase.to, testCase.allowed, err)
+		}
+	}
+
+	if err := CanUpgrade("1.2", "1.3.0"); err == nil {
+		t.Fatal("expected an error for an invalid version")
+	}
+}
+
+func TestPluginCompatible(t *testing.T) {
+	var testCases = []struct {
+		host       string
+		plugin     string
+		compatible bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"1.2.0", "1.2.5", true},
+		{"1.5.3", "1.2.0", true},
+		{"v1.3.0-rc.1", "v1.3.0", true},
+		// plugins requiring a newer minor version
+		{"1.2.9", "1.3.0", false},
+		// different major versions
+		{"2.0.0", "1.9.0", false},
+		{"1.9.0", "2.0.0", false},
+	}
+	for _, testCase := range testCases {
+		err := PluginCompatible(testCase.host, testCase.plugin)
+		if (err == nil) != testCase.compatible {
+			t.Fatalf("expected plugin built against %v to be compatible with host %v: %v but got %v",
+				testCase.plugin, testCase.host, testCase.compatible, err)
+		}
+	}
+
+	if err := PluginCompatible("1.2", "1.2.0"); err == nil {
+		t.Fatal("expected an error for an invalid version")
+	}
+}
+
+func TestSafeReload(t *testing.T) {
+	var testCases = []struct {
+		old      string
+		new      string
+		expected bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		// patch and metadata differences
+		{"1.2.0", "1.2.5", true},
+		{"v1.2.5", "v1.2.3", true},
+		{"1.2.0+2032d5b", "1.2.0+a1b2c3d", true},
+		{"1.2.1-rc.1", "1.2.1", true},
+		// minor differences
+		{"1.2.5", "1.3.0", false},
+		{"1.3.0", "1.2.5", false},
+		// major differences
+		{"1.2.0", "2.2.0", false},
+		// invalid versions
+		{"1.2", "1.2.0", false},
+		{"1.2.0", "", false},
+	}
+	for _, testCase := range testCases {
+		result := SafeReload(Info{Version: testCase.old}, Info{Version: testCase.new})
+		if result != testCase.expected {
+			t.Fatalf("expected reload from %v to %v to be safe: %v but got %v", testCase.old, testCase.new, testCase.expected, result)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Constraint is a parsed version constraint, e.g. `>=1.2.0, <2.0.0`.
+//
+// A constraint is a comma-separated list of comparisons that all must match.
+// A comparison is a version with an optional operator:
+//   - `=` (default) and `!=` match versions of the same or different precedence
+//   - `>`, `>=`, `<` and `<=` compare versions by precedence
+//   - `~1.2.3` matches versions at or above 1.2.3 with the same major and minor version
+//   - `^1.2.3` matches API-compatible versions at or above 1.2.3 (see APICompatible)
+type Constraint struct {
+	comparisons []comparison
+}
+
+// comparison compares versions to version using operator.
+type comparison struct {
+	operator string
+	version  Semver
+}
+
+// constraintOperators lists the supported operators.
+// Operators that are prefixes of other operators come last.
+var constraintOperators = []string{">=", "<=", "!=", ">", "<", "=", "~", "^"}
+
+// ParseConstraint parses the version constraint s.
+func ParseConstraint(s string) (Constraint, error) {
+	var result Constraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		operator := "="
+		for _, candidate := range constraintOperators {
+			if strings.HasPrefix(part, candidate) {
+				operator = candidate
+				part = strings.TrimSpace(strings.TrimPrefix(part, candidate))
+				break
+			}
+		}
+		parsed, err := ParseSemver(part)
+		if err != nil {
+			return Constraint{}, fmt.Errorf("invalid constraint %q: %v", s, err)
+		}
+		result.comparisons = append(result.comparisons, comparison{operator: operator, version: *parsed})
+	}
+	return result, nil
+}
+
+// Matches determines if version v satisfies the constraint.
+// Invalid versions never match.
+func (r Constraint) Matches(v string) bool {
+	parsed, err := ParseSemver(v)
+	if err != nil {
+		return false
+	}
+	for _, comparison := range r.comparisons {
+		if !comparison.matches(*parsed) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r comparison) matches(v Semver) bool {
+	result := v.Compare(r.version)
+	switch r.operator {
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case "~":
+		return result >= 0 && v.Major == r.version.Major && v.Minor == r.version.Minor
+	case "^":
+		return apiCompatible(r.version, v)
+	}
+	return result == 0
+}
+
+// Satisfies determines if version v satisfies constraint.
+// See Constraint for the syntax of constraints.
+func Satisfies(v, constraint string) (bool, error) {
+	parsed, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	if _, err = ParseSemver(v); err != nil {
+		return false, err
+	}
+	return parsed.Matches(v), nil
+}
@@ -0,0 +1,64 @@
+package version
+
+import "testing"
+
+func TestConstraint(t *testing.T) {
+	var testCases = []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{"1.2.0", "1.2.0", true},
+		{"=1.2.0", "1.2.0+2032d5b", true},
+		{"1.2.0", "1.2.1", false},
+		{"!=1.2.0", "1.2.1", true},
+		{">1.2.0", "1.2.1", true},
+		{">1.2.0", "1.2.0", false},
+		{">= 1.2.0", "1.2.0", true},
+		{"<2.0.0", "2.0.0-rc.1", true},
+		{"<=1.9.9", "2.0.0", false},
+		{">=1.2.0, <2.0.0", "1.9.9", true},
+		{">=1.2.0, <2.0.0", "2.0.0", false},
+		{">=1.2.0,<2.0.0", "1.1.9", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1.2.3", "1.2.2", false},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.3.0", false},
+		{"^v1.2.3", "v1.2.4", true},
+		{">=1.0.0", "not-a-version", false},
+	}
+	for _, testCase := range testCases {
+		constraint, err := ParseConstraint(testCase.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := constraint.Matches(testCase.version); result != testCase.expected {
+			t.Fatalf("expected %v for %v with constraint %q but got %v",
+				testCase.expected, testCase.version, testCase.constraint, result)
+		}
+	}
+
+	for _, constraint := range []string{"", ">=1.2", "=>1.2.0", ">=1.2.0,", "1.2.0 || 2.0.0"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Fatalf("expected an error for constraint %q", constraint)
+		}
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	result, err := Satisfies("1.5.0", ">=1.2.0, <2.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result {
+		t.Fatal("expected 1.5.0 to satisfy the constraint")
+	}
+	if _, err = Satisfies("1.5", ">=1.2.0"); err == nil {
+		t.Fatal("expected an error for an invalid version")
+	}
+	if _, err = Satisfies("1.5.0", ">=1.2"); err == nil {
+		t.Fatal("expected an error for an invalid constraint")
+	}
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// semverPattern defines a regexp pattern to modify the results of `git describe` to be semver-complaint.
+//
+// it matches versions like: 3.13.0-3-g2032d5b
+var semverPattern = regexp.MustCompile(`([0-9]+)\.([0-9]+)\.([0-9]+)-([0-9]{1,})-g([0-9a-f]{4,40})$`)
+
+// describeCommitPattern matches the abbreviated commit ID at the end of `git describe` output.
+var describeCommitPattern = regexp.MustCompile(`-[0-9]+-g([0-9a-f]+)$`)
+
+// FromDescribe computes the version information from the output of `git describe`
+// and the state of the git tree without invoking git.
+//
+// The `-dirty` and `-broken` markers of `git describe --dirty --broken` are
+// recognized and reflected in Dirty and Broken.
+// GitCommit is the abbreviated commit ID from describe and is empty if the commit is tagged.
+func FromDescribe(describe string, dirty bool) Info {
+	var info Info
+	if strings.HasSuffix(describe, "-"+describeMarkerDirty) {
+		describe = strings.TrimSuffix(describe, "-"+describeMarkerDirty)
+		dirty = true
+	} else if strings.HasSuffix(describe, "-"+describeMarkerBroken) {
+		describe = strings.TrimSuffix(describe, "-"+describeMarkerBroken)
+		info.Broken = true
+	}
+	info.Dirty = dirty
+	if describe != "" {
+		info.Version = semverify(describe)
+		if dirty {
+			info.Version = info.Version + "-" + treeStateDirty
+		}
+	}
+	if match := describeCommitPattern.FindStringSubmatch(describe); match != nil {
+		info.GitCommit = match[1]
+	}
+	info.GitTreeState = treeStateClean
+	if dirty {
+		info.GitTreeState = treeStateDirty
+	}
+	return info
+}
+
+const (
+	treeStateClean = "clean"
+	treeStateDirty = "dirty"
+)
+
+// Markers appended by `git describe --dirty --broken`
+const (
+	describeMarkerDirty  = "dirty"
+	describeMarkerBroken = "broken"
+)
+
+// semverify transforms the output of `git describe` to be semver-compliant.
+func semverify(version string) string {
+	match := semverPattern.FindStringSubmatch(version)
+	if match != nil && len(match) == 6 {
+		// replace the last component of the semver (which is always 0 in our versioning scheme)
+		// with the number of commits since the last tag.
+		// The tagged commit itself (e.g. described with --long) keeps the patch of its tag.
+		patch := match[4]
+		if patch == "0" {
+			patch = match[3]
+		}
+		return fmt.Sprintf("%v.%v.%v+%v", match[1], match[2], patch, match[5])
+	}
+	return version
+}
+
+// metadataCommitPattern matches the abbreviated commit ID in the build metadata
+// of versions computed from `git describe`, e.g. `1.2.3+2032d5b-dirty`.
+var metadataCommitPattern = regexp.MustCompile(`\+([0-9a-f]{4,40})(?:-` + treeStateDirty + `)?$`)
+
+// ConsistencyCheck verifies that the abbreviated commit ID in the build metadata of Version
+// is a prefix of GitCommit. A mismatch indicates stale or mismatched linker flags.
+// Versions without a commit ID in their metadata and builds without GitCommit pass the check.
+func (r Info) ConsistencyCheck() error {
+	match := metadataCommitPattern.FindStringSubmatch(r.Version)
+	if match == nil || r.GitCommit == "" || r.GitCommit == defaultGitCommit {
+		return nil
+	}
+	if !strings.HasPrefix(r.GitCommit, match[1]) {
+		return fmt.Errorf("commit %v of version %v does not match git commit %v", match[1], r.Version, r.GitCommit)
+	}
+	return nil
+}
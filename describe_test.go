@@ -0,0 +1,125 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromDescribe(t *testing.T) {
+	var testCases = []struct {
+		describe string
+		dirty    bool
+		expected Info
+	}{
+		{
+			describe: "v1.0.0",
+			expected: Info{Version: "v1.0.0", GitTreeState: "clean"},
+		},
+		{
+			describe: "v1.0.0",
+			dirty:    true,
+			expected: Info{Version: "v1.0.0-dirty", GitTreeState: "dirty", Dirty: true},
+		},
+		{
+			describe: "v3.13.0-3-g2032d5b1a2b3c4",
+			expected: Info{Version: "3.13.3+2032d5b1a2b3c4", GitCommit: "2032d5b1a2b3c4", GitTreeState: "clean"},
+		},
+		{
+			describe: "v3.13.0-3-g2032d5b1a2b3c4",
+			dirty:    true,
+			expected: Info{Version: "3.13.3+2032d5b1a2b3c4-dirty", GitCommit: "2032d5b1a2b3c4", GitTreeState: "dirty", Dirty: true},
+		},
+		{
+			describe: "release-12-g2032d5b",
+			expected: Info{Version: "release-12-g2032d5b", GitCommit: "2032d5b", GitTreeState: "clean"},
+		},
+		{
+			describe: "",
+			dirty:    true,
+			expected: Info{GitTreeState: "dirty", Dirty: true},
+		},
+	}
+	for _, testCase := range testCases {
+		info := FromDescribe(testCase.describe, testCase.dirty)
+		if !reflect.DeepEqual(info, testCase.expected) {
+			t.Fatalf("expected %+v for %q (dirty=%v) but got %+v", testCase.expected, testCase.describe, testCase.dirty, info)
+		}
+	}
+}
+
+func TestFromDescribeMarkers(t *testing.T) {
+	var testCases = []struct {
+		describe string
+		expected Info
+	}{
+		{
+			describe: "v1.2.0-3-g2032d5b",
+			expected: Info{Version: "1.2.3+2032d5b", GitCommit: "2032d5b", GitTreeState: "clean"},
+		},
+		{
+			describe: "v1.2.0-3-g2032d5b-dirty",
+			expected: Info{Version: "1.2.3+2032d5b-dirty", GitCommit: "2032d5b", GitTreeState: "dirty", Dirty: true},
+		},
+		{
+			describe: "v1.2.0-dirty",
+			expected: Info{Version: "v1.2.0-dirty", GitTreeState: "dirty", Dirty: true},
+		},
+		{
+			describe: "v1.2.0-3-g2032d5b-broken",
+			expected: Info{Version: "1.2.3+2032d5b", GitCommit: "2032d5b", GitTreeState: "clean", Broken: true},
+		},
+	}
+	for _, testCase := range testCases {
+		info := FromDescribe(testCase.describe, false)
+		if !reflect.DeepEqual(info, testCase.expected) {
+			t.Fatalf("expected %+v for %q but got %+v", testCase.expected, testCase.describe, info)
+		}
+	}
+}
+
+func TestSemverifyAbbrev(t *testing.T) {
+	for _, describe := range []string{"v1.2.0-5-g2032", "v1.2.0-5-g2032d5b", "v1.2.0-5-g2032d5b1a2b3c4d5e6f70123456789abcdef0123"} {
+		expected := "1.2.5+" + describe[len("v1.2.0-5-g"):]
+		if result := semverify(describe); result != expected {
+			t.Fatalf("expected %v for %v but got %v", expected, describe, result)
+		}
+	}
+}
+
+func TestSemverifyTagged(t *testing.T) {
+	var testCases = []struct {
+		describe string
+		expected string
+	}{
+		{"v1.2.0-0-g2032d5b", "1.2.0+2032d5b"},
+		{"v1.2.5-0-g2032d5b", "1.2.5+2032d5b"},
+		{"v1.2.0-3-g2032d5b", "1.2.3+2032d5b"},
+	}
+	for _, testCase := range testCases {
+		if result := semverify(testCase.describe); result != testCase.expected {
+			t.Fatalf("expected %v for %v but got %v", testCase.expected, testCase.describe, result)
+		}
+	}
+}
+
+func TestConsistencyCheck(t *testing.T) {
+	const commitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+	var testCases = []struct {
+		info       Info
+		consistent bool
+	}{
+		{Info{Version: "1.2.3+2032d5b1a2b3c4", GitCommit: commitID}, true},
+		{Info{Version: "1.2.3+2032d5b-dirty", GitCommit: commitID}, true},
+		{Info{Version: "v1.2.0", GitCommit: commitID}, true},
+		{Info{Version: "1.2.3+build.5", GitCommit: commitID}, true},
+		{Info{Version: "1.2.3+2032d5b1a2b3c4"}, true},
+		{Info{Version: "1.2.3+a1b2c3d4e5f607", GitCommit: commitID}, false},
+		{Info{Version: "1.2.3+a1b2c3d-dirty", GitCommit: commitID}, false},
+	}
+	for _, testCase := range testCases {
+		err := testCase.info.ConsistencyCheck()
+		if (err == nil) != testCase.consistent {
+			t.Fatalf("expected %+v to be consistent: %v but got %v", testCase.info, testCase.consistent, err)
+		}
+	}
+}
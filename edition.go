@@ -0,0 +1,45 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+// Product editions
+const (
+	EditionCommunity  = "community"
+	EditionEnterprise = "enterprise"
+)
+
+// Editions lists the supported product editions
+var Editions = []string{EditionCommunity, EditionEnterprise}
+
+// ValidEdition determines if edition is one of the supported Editions.
+func ValidEdition(edition string) bool {
+	for _, e := range Editions {
+		if e == edition {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnterprise determines if this is a build of the enterprise edition.
+func (r Info) IsEnterprise() bool {
+	return r.Edition == EditionEnterprise
+}
+
+// IsCommunity determines if this is a build of the community edition.
+func (r Info) IsCommunity() bool {
+	return r.Edition == EditionCommunity
+}
@@ -0,0 +1,34 @@
+package version
+
+import "testing"
+
+func TestEdition(t *testing.T) {
+	defer saveVars()()
+
+	var testCases = []struct {
+		edition    string
+		valid      bool
+		enterprise bool
+		community  bool
+	}{
+		{edition: EditionEnterprise, valid: true, enterprise: true},
+		{edition: EditionCommunity, valid: true, community: true},
+		{edition: "Enterprise"},
+		{edition: "premium"},
+		{edition: ""},
+	}
+	for _, testCase := range testCases {
+		if valid := ValidEdition(testCase.edition); valid != testCase.valid {
+			t.Fatalf("expected edition %q to be valid: %v but got %v", testCase.edition, testCase.valid, valid)
+		}
+		Set(Info{Edition: testCase.edition})
+		info := Get()
+		if info.Edition != testCase.edition {
+			t.Fatalf("expected edition %q but got %q", testCase.edition, info.Edition)
+		}
+		if info.IsEnterprise() != testCase.enterprise || info.IsCommunity() != testCase.community {
+			t.Fatalf("expected enterprise: %v, community: %v for edition %q but got %v, %v", testCase.enterprise,
+				testCase.community, testCase.edition, info.IsEnterprise(), info.IsCommunity())
+		}
+	}
+}
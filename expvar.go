@@ -0,0 +1,41 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"expvar"
+	"sync"
+)
+
+// expvarName is the name of the variable published by PublishExpvar
+const expvarName = "version"
+
+var publishExpvarOnce sync.Once
+
+// PublishExpvar publishes the version information as expvar variable `version`
+// so that it is served on /debug/vars.
+// It is safe to call multiple times; the variable is only published once and
+// nothing is published if another variable named `version` already exists.
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		if expvar.Get(expvarName) != nil {
+			return
+		}
+		expvar.Publish(expvarName, expvar.Func(func() interface{} {
+			return Get()
+		}))
+	})
+}
@@ -0,0 +1,27 @@
+package version
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	defer saveVars()()
+	Set(Info{Version: "1.2.0", GitCommit: "2032d5b", GitTreeState: "clean"})
+
+	PublishExpvar()
+	PublishExpvar()
+
+	published := expvar.Get(expvarName)
+	if published == nil {
+		t.Fatal("expected the version information to be published")
+	}
+	var info Info
+	if err := json.Unmarshal([]byte(published.String()), &info); err != nil {
+		t.Fatalf("expected valid JSON but got %v", err)
+	}
+	if info.Version != "1.2.0" || info.GitCommit != "2032d5b" {
+		t.Fatalf("expected the published version information to match the build but got %+v", info)
+	}
+}
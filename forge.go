@@ -0,0 +1,157 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Forge is a type of git hosting service.
+type Forge int
+
+// Supported forges
+const (
+	ForgeUnknown Forge = iota
+	ForgeGitHub
+	ForgeGitLab
+	ForgeBitbucket
+	ForgeGitea
+)
+
+// String returns the name of the forge.
+func (r Forge) String() string {
+	switch r {
+	case ForgeGitHub:
+		return "GitHub"
+	case ForgeGitLab:
+		return "GitLab"
+	case ForgeBitbucket:
+		return "Bitbucket"
+	case ForgeGitea:
+		return "Gitea"
+	}
+	return "unknown"
+}
+
+// DetectForge determines the forge hosting the repository at git remote remoteURL
+// from its host name. Remotes may be given as URLs (`https://github.com/org/repo.git`)
+// or in scp-like syntax (`git@github.com:org/repo.git`).
+// Self-hosted instances are detected if the name of the forge is part of the host name,
+// e.g. `gitlab.example.com`.
+func DetectForge(remoteURL string) (Forge, error) {
+	forge, _, _, err := parseForgeRemote(remoteURL)
+	return forge, err
+}
+
+// parseForgeRemote returns the forge, the host and the repository path of git remote remote.
+func parseForgeRemote(remote string) (forge Forge, host, path string, err error) {
+	host, path, err = parseRemote(remote)
+	if err != nil {
+		return ForgeUnknown, "", "", err
+	}
+	switch {
+	case strings.Contains(host, "github"):
+		forge = ForgeGitHub
+	case strings.Contains(host, "gitlab"):
+		forge = ForgeGitLab
+	case strings.Contains(host, "bitbucket"):
+		forge = ForgeBitbucket
+	case strings.Contains(host, "gitea"), host == "codeberg.org":
+		forge = ForgeGitea
+	default:
+		return ForgeUnknown, "", "", fmt.Errorf("unknown forge of git remote %q", remote)
+	}
+	return forge, host, path, nil
+}
+
+// CompareURL returns the URL of the web view comparing revisions from and to
+// (versions, tags or commits) of the repository at git remote remote.
+// GitHub, GitLab and Gitea remotes are supported; see DetectForge.
+func CompareURL(remote, from, to string) (string, error) {
+	forge, host, path, err := parseForgeRemote(remote)
+	if err != nil {
+		return "", err
+	}
+	switch forge {
+	case ForgeGitHub, ForgeGitea:
+		return fmt.Sprintf("https://%v/%v/compare/%v...%v", host, path, from, to), nil
+	case ForgeGitLab:
+		return fmt.Sprintf("https://%v/%v/-/compare/%v...%v", host, path, from, to), nil
+	}
+	return "", fmt.Errorf("compare URLs are not supported for %v remote %q", forge, remote)
+}
+
+// parseRemote returns the host and the repository path of git remote remote.
+func parseRemote(remote string) (host, path string, err error) {
+	if !strings.Contains(remote, "://") {
+		// scp-like syntax: [user@]host:path
+		i := strings.Index(remote, ":")
+		if i <= 0 {
+			return "", "", fmt.Errorf("invalid git remote %q", remote)
+		}
+		remote = "ssh://" + remote[:i] + "/" + remote[i+1:]
+	}
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid git remote %q: %v", remote, err)
+	}
+	path = strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".git")
+	if parsed.Hostname() == "" || path == "" {
+		return "", "", fmt.Errorf("invalid git remote %q", remote)
+	}
+	return parsed.Hostname(), path, nil
+}
+
+// CommitLink returns the abbreviated commit ID of the build as a terminal hyperlink
+// (OSC 8 escape sequence) to the commit on the forge of git remote remote.
+// It returns the plain abbreviated commit ID if the forge of the remote is unknown
+// or the standard output is not a terminal.
+func (r Info) CommitLink(remote string) string {
+	text := r.shortCommit()
+	if text == "" || !isTerminal() {
+		return text
+	}
+	link, err := commitURL(remote, r.GitCommit)
+	if err != nil {
+		return text
+	}
+	return "\x1b]8;;" + link + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// commitURL returns the URL of the web view of commit in the repository at git remote remote.
+func commitURL(remote, commit string) (string, error) {
+	forge, host, path, err := parseForgeRemote(remote)
+	if err != nil {
+		return "", err
+	}
+	switch forge {
+	case ForgeGitLab:
+		return fmt.Sprintf("https://%v/%v/-/commit/%v", host, path, commit), nil
+	case ForgeBitbucket:
+		return fmt.Sprintf("https://%v/%v/commits/%v", host, path, commit), nil
+	}
+	return fmt.Sprintf("https://%v/%v/commit/%v", host, path, commit), nil
+}
+
+// isTerminal determines if the standard output is a terminal.
+// It is a variable to be replaced in tests.
+var isTerminal = func() bool {
+	stat, err := os.Stdout.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
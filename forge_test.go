@@ -0,0 +1,100 @@
+package version
+
+import "testing"
+
+func TestDetectForge(t *testing.T) {
+	var testCases = []struct {
+		remote   string
+		expected Forge
+	}{
+		{"git@github.com:gravitational/version.git", ForgeGitHub},
+		{"https://github.com/gravitational/version", ForgeGitHub},
+		{"ssh://git@github.example.com/org/repo.git", ForgeGitHub},
+		{"git@gitlab.com:group/subgroup/project.git", ForgeGitLab},
+		{"https://gitlab.example.com:8443/group/project.git", ForgeGitLab},
+		{"git@bitbucket.org:org/repo.git", ForgeBitbucket},
+		{"https://user@bitbucket.org/org/repo.git", ForgeBitbucket},
+		{"git@gitea.example.com:org/repo.git", ForgeGitea},
+		{"https://codeberg.org/org/repo", ForgeGitea},
+	}
+	for _, testCase := range testCases {
+		forge, err := DetectForge(testCase.remote)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if forge != testCase.expected {
+			t.Fatalf("expected %v for remote %v but got %v", testCase.expected, testCase.remote, forge)
+		}
+	}
+
+	for _, remote := range []string{"git@git.example.com:org/repo.git", "/srv/git/repo.git", "https://github.com/", ""} {
+		if forge, err := DetectForge(remote); err == nil {
+			t.Fatalf("expected an error for remote %q but got %v", remote, forge)
+		}
+	}
+}
+
+func TestCompareURL(t *testing.T) {
+	var testCases = []struct {
+		remote   string
+		expected string
+	}{
+		{"git@github.com:gravitational/version.git", "https://github.com/gravitational/version/compare/v1.0.0...v1.1.0"},
+		{"https://github.com/gravitational/version", "https://github.com/gravitational/version/compare/v1.0.0...v1.1.0"},
+		{"ssh://git@github.com/gravitational/version.git", "https://github.com/gravitational/version/compare/v1.0.0...v1.1.0"},
+		{"git@gitlab.com:group/subgroup/project.git", "https://gitlab.com/group/subgroup/project/-/compare/v1.0.0...v1.1.0"},
+		{"https://gitlab.example.com:8443/group/project.git", "https://gitlab.example.com/group/project/-/compare/v1.0.0...v1.1.0"},
+		{"https://codeberg.org/org/repo.git", "https://codeberg.org/org/repo/compare/v1.0.0...v1.1.0"},
+	}
+	for _, testCase := range testCases {
+		url, err := CompareURL(testCase.remote, "v1.0.0", "v1.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if url != testCase.expected {
+			t.Fatalf("expected %v for remote %v but got %v", testCase.expected, testCase.remote, url)
+		}
+	}
+
+	for _, remote := range []string{"git@bitbucket.org:org/repo.git", "git@git.example.com:org/repo.git", "/srv/git/repo.git", ""} {
+		if _, err := CompareURL(remote, "v1.0.0", "v1.1.0"); err == nil {
+			t.Fatalf("expected an error for remote %q", remote)
+		}
+	}
+}
+
+func TestCommitLink(t *testing.T) {
+	defer func(terminal func() bool) { isTerminal = terminal }(isTerminal)
+	info := Info{GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123"}
+
+	isTerminal = func() bool { return true }
+	var testCases = []struct {
+		remote   string
+		expected string
+	}{
+		{
+			"git@github.com:gravitational/version.git",
+			"\x1b]8;;https://github.com/gravitational/version/commit/2032d5b1a2b3c4d5e6f70123456789abcdef0123\x1b\\2032d5b1a2b3\x1b]8;;\x1b\\",
+		},
+		{
+			"https://gitlab.com/group/project.git",
+			"\x1b]8;;https://gitlab.com/group/project/-/commit/2032d5b1a2b3c4d5e6f70123456789abcdef0123\x1b\\2032d5b1a2b3\x1b]8;;\x1b\\",
+		},
+		{
+			"git@bitbucket.org:org/repo.git",
+			"\x1b]8;;https://bitbucket.org/org/repo/commits/2032d5b1a2b3c4d5e6f70123456789abcdef0123\x1b\\2032d5b1a2b3\x1b]8;;\x1b\\",
+		},
+		{"git@git.example.com:org/repo.git", "2032d5b1a2b3"},
+		{"", "2032d5b1a2b3"},
+	}
+	for _, testCase := range testCases {
+		if link := info.CommitLink(testCase.remote); link != testCase.expected {
+			t.Fatalf("expected %q for remote %q but got %q", testCase.expected, testCase.remote, link)
+		}
+	}
+
+	isTerminal = func() bool { return false }
+	if link := info.CommitLink("git@github.com:gravitational/version.git"); link != "2032d5b1a2b3" {
+		t.Fatalf("expected the plain commit if the output is not a terminal but got %q", link)
+	}
+}
@@ -0,0 +1,384 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"hash/fnv"
+	"html"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// field is a named value of the version information used for presentation.
+type field struct {
+	name  string
+	value string
+}
+
+// fields returns the human-readable fields of the version information.
+// Empty values are omitted.
+func (r Info) fields() []field {
+	all := []field{
+		{"Version", r.Version},
+		{"Git commit", r.GitCommit},
+		{"Git tree state", r.GitTreeState},
+		{"Git branch", r.GitBranch},
+		{"Platform", r.Platform()},
+		{"Source tree hash", r.SourceTreeHash},
+	}
+	var result []field
+	for _, f := range all {
+		if f.value != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// HTML renders the version information as an HTML definition list
+// suitable for embedding into status pages.
+// All values are escaped.
+func (r Info) HTML() template.HTML {
+	var buf bytes.Buffer
+	buf.WriteString(`<dl class="version">`)
+	for _, f := range r.fields() {
+		buf.WriteString("<dt>")
+		buf.WriteString(html.EscapeString(f.name))
+		buf.WriteString("</dt><dd>")
+		buf.WriteString(html.EscapeString(f.value))
+		buf.WriteString("</dd>")
+	}
+	buf.WriteString("</dl>")
+	return template.HTML(buf.String())
+}
+
+// maxServerInfoLength limits the length of the string returned by ServerInfo
+const maxServerInfoLength = 128
+
+// shortCommitLength is the length of abbreviated commit IDs in human-readable output
+const shortCommitLength = 12
+
+// ServerInfo returns a single-line description of the program and its version
+// suitable for service banners such as the gRPC server reflection info,
+// e.g. `myserver/1.2.0 (commit 2032d5b1a2b3)`.
+// The result has no more than 128 bytes and is truncated at a character boundary.
+func (r Info) ServerInfo() string {
+	return r.serverInfo(programName())
+}
+
+func (r Info) serverInfo(product string) string {
+	result := product
+	if !r.unversioned() {
+		result += "/" + r.Version
+	}
+	if commit := r.shortCommit(); commit != "" {
+		result += " (commit " + commit + ")"
+	}
+	result = strings.Join(strings.Fields(result), " ")
+	if len(result) > maxServerInfoLength {
+		end := maxServerInfoLength - 3
+		for end > 0 && !utf8.RuneStart(result[end]) {
+			end--
+		}
+		result = result[:end] + "..."
+	}
+	return result
+}
+
+// shortCommit returns the abbreviated git commit ID
+// or an empty string if the commit is unknown.
+func (r Info) shortCommit() string {
+	if r.GitCommit == defaultGitCommit {
+		return ""
+	}
+	if len(r.GitCommit) > shortCommitLength {
+		return r.GitCommit[:shortCommitLength]
+	}
+	return r.GitCommit
+}
+
+// unversioned determines if the build has no version: the version has not been
+// set with the linker flags or is empty.
+func (r Info) unversioned() bool {
+	return r.Version == "" || placeholder(r.Version)
+}
+
+// knownVersion returns the version or an empty string for builds without version.
+func (r Info) knownVersion() string {
+	if r.unversioned() {
+		return ""
+	}
+	return r.Version
+}
+
+// knownCommit returns the git commit ID or an empty string if the commit is unknown
+// or the placeholder of a build without linker flags.
+func (r Info) knownCommit() string {
+	if placeholder(r.GitCommit) {
+		return ""
+	}
+	return r.GitCommit
+}
+
+// programName returns the name of the running program.
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// MetricLabels returns the version information as labels for a Prometheus metric
+// (e.g. a `build_info` gauge).
+// Characters other than ASCII letters, digits, `_`, `.` and `-` are replaced with `_`
+// so that the values are safe for any metrics pipeline.
+// The version and revision labels are empty for builds without version information.
+func (r Info) MetricLabels() map[string]string {
+	return map[string]string{
+		"version":   sanitizeLabel(r.knownVersion()),
+		"revision":  sanitizeLabel(r.knownCommit()),
+		"branch":    sanitizeLabel(r.GitBranch),
+		"goversion": sanitizeLabel(r.GoVersion),
+	}
+}
+
+// JournalFields returns the version information as fields for a systemd journal entry
+// (e.g. for sd_journal_send or a structured logger writing to the journal).
+// Keys follow the journal field naming rules: uppercase ASCII letters, digits and `_`.
+// The version and the commit are empty for builds without version information.
+func (r Info) JournalFields() map[string]string {
+	return map[string]string{
+		"VERSION":    r.knownVersion(),
+		"GIT_COMMIT": r.knownCommit(),
+		"GO_VERSION": r.GoVersion,
+	}
+}
+
+// sanitizeLabel replaces characters unsafe for metric label values with `_`.
+func sanitizeLabel(value string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' {
+			return c
+		}
+		return '_'
+	}, value)
+}
+
+// GeneratedHeader returns a comment line marking a Go source file as generated
+// by this build, e.g.:
+//
+//	// Code generated at version 1.2.0 (commit 2032d5b1a2b3) — DO NOT EDIT.
+//
+// The comment follows the convention recognized by Go tools (https://go.dev/s/generatedcode).
+func (r Info) GeneratedHeader() string {
+	header := "// Code generated"
+	if !r.unversioned() {
+		header += " at version " + r.Version
+	}
+	if commit := r.shortCommit(); commit != "" {
+		header += " (commit " + commit + ")"
+	}
+	return header + " — DO NOT EDIT."
+}
+
+// GoStyle returns the version of the running program in the style of `go version`, e.g.:
+//
+//	mytool version v1.2.0 go1.22.1 linux/amd64
+//
+// The version and the Go version are omitted if unknown, e.g. in builds without version information.
+func GoStyle() string {
+	return Get().goStyle(programName())
+}
+
+func (r Info) goStyle(program string) string {
+	parts := []string{program, "version"}
+	if !r.unversioned() {
+		parts = append(parts, r.Version)
+	}
+	if r.GoVersion != "" {
+		parts = append(parts, r.GoVersion)
+	}
+	platform := r.Platform()
+	if platform == "" {
+		platform = runtime.GOOS + "/" + runtime.GOARCH
+	}
+	return strings.Join(append(parts, platform), " ")
+}
+
+// AuditEventType is the type of the event returned by AuditEvent
+const AuditEventType = "version.start"
+
+// AuditEvent returns a structured event describing the running build for audit logs,
+// e.g. to be recorded when a program starts.
+// The event always contains the fields type, timestamp (RFC 3339), version, commit and builder
+// (`user@host` of the build or empty if unknown).
+// The version and the commit are empty for builds without version information.
+func (r Info) AuditEvent() map[string]interface{} {
+	return r.auditEvent(time.Now())
+}
+
+func (r Info) auditEvent(now time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      AuditEventType,
+		"timestamp": now.UTC().Format(time.RFC3339),
+		"version":   r.knownVersion(),
+		"commit":    r.knownCommit(),
+		"builder":   r.builder(),
+	}
+}
+
+// builder returns the user and host of the build as `user@host`.
+// Either part is omitted if unknown.
+func (r Info) builder() string {
+	if r.BuildUser != "" && r.BuildHost != "" {
+		return r.BuildUser + "@" + r.BuildHost
+	}
+	return r.BuildUser + r.BuildHost
+}
+
+// ArtifactName returns a file name for a release artifact of product built for the given
+// operating system and architecture, e.g. `mytool-1.2.0-linux-amd64`.
+// Characters other than ASCII letters, digits, `_`, `.` and `-` (e.g. the `+` separating
+// build metadata) are replaced with `-` so that the name is safe for file systems and
+// Content-Disposition headers: `1.2.3+2032d5b-dirty` becomes `1.2.3-2032d5b-dirty`.
+// Empty parts and the version of builds without version information are omitted.
+func (r Info) ArtifactName(product, goos, goarch string) string {
+	var version string
+	if !r.unversioned() {
+		version = r.Version
+	}
+	var parts []string
+	for _, part := range []string{product, version, goos, goarch} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-' {
+			return c
+		}
+		return '-'
+	}, strings.Join(parts, "-"))
+}
+
+// BugReport returns a fenced Markdown block with the version information
+// to be pasted into bug reports, e.g.:
+//
+//	```
+//	Version:    1.2.0
+//	Git commit: 2032d5b1a2b3c4d5e6f70123456789abcdef0123
+//	Go version: go1.22.1
+//	Platform:   linux/amd64
+//	```
+//
+// Unknown fields and placeholders of builds without version information are omitted.
+func (r Info) BugReport() string {
+	var buf bytes.Buffer
+	buf.WriteString("```\n")
+	for _, field := range []field{
+		{"Version", r.Version},
+		{"Git commit", r.GitCommit},
+		{"Go version", r.GoVersion},
+		{"Platform", r.Platform()},
+	} {
+		if field.value != "" && !placeholder(field.value) {
+			fmt.Fprintf(&buf, "%-11s %v\n", field.name+":", field.value)
+		}
+	}
+	buf.WriteString("```\n")
+	return buf.String()
+}
+
+// About returns a multi-line description of the build of product for an about dialog, e.g.:
+//
+//	mytool
+//	Version 1.2.0 (commit 2032d5b1a2b3)
+//	Built on 2024-06-01T10:30:00Z
+//	Go version go1.22.1
+//
+// Lines for unknown fields are omitted.
+func (r Info) About(product string) string {
+	lines := []string{product}
+	if !r.unversioned() {
+		line := "Version " + r.Version
+		if commit := r.shortCommit(); commit != "" {
+			line += " (commit " + commit + ")"
+		}
+		lines = append(lines, line)
+	} else if commit := r.shortCommit(); commit != "" {
+		lines = append(lines, "Commit "+commit)
+	}
+	if r.BuildTime != "" {
+		lines = append(lines, "Built on "+r.BuildTime)
+	}
+	if r.GoVersion != "" {
+		lines = append(lines, "Go version "+r.GoVersion)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StatusBar returns a compact form of the version for status bars of editors and CLIs,
+// e.g. `v1.2.0*` where the asterisk marks a build from a dirty tree.
+// Build metadata and the `-dirty` suffix are omitted for brevity and builds without version
+// show the abbreviated commit ID.
+func (r Info) StatusBar() string {
+	var status string
+	if r.unversioned() {
+		status = r.shortCommit()
+	} else {
+		status = r.Version
+		if i := strings.IndexByte(status, '+'); i >= 0 {
+			status = status[:i]
+		}
+		status = strings.TrimSuffix(status, "-"+treeStateDirty)
+	}
+	if status != "" && r.Dirty {
+		status += "*"
+	}
+	return status
+}
+
+// GoConst returns a Go constant declaration named name with the version as its value,
+// e.g. `const name = "1.2.0"`, for code generators embedding the version in Go source files.
+// The declaration is formatted as by gofmt.
+// The value is empty for builds without version information.
+// It returns an error if name is not a valid Go identifier.
+func (r Info) GoConst(name string) (string, error) {
+	if !token.IsIdentifier(name) {
+		return "", fmt.Errorf("invalid Go identifier %q", name)
+	}
+	return fmt.Sprintf("const %v = %v", name, strconv.Quote(r.knownVersion())), nil
+}
+
+// CacheBust returns a short URL-safe token identifying the build for cache busting
+// of web assets, e.g. `app.js?v=<token>`.
+// The token is a hash of the version and the commit or the abbreviated commit ID
+// for builds without version. It is empty if neither is known.
+func (r Info) CacheBust() string {
+	if r.unversioned() {
+		return url.QueryEscape(r.shortCommit())
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(r.Version + "+" + r.GitCommit))
+	return fmt.Sprintf("%08x", hash.Sum32())
+}
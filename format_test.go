@@ -0,0 +1,454 @@
+package version
+
+import (
+	"go/format"
+	"html/template"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestHTML(t *testing.T) {
+	info := Info{
+		Version:      `1.0.0<script>alert("x")</script>`,
+		GitCommit:    "2032d5b",
+		GitTreeState: "clean",
+	}
+	expected := template.HTML(`<dl class="version">` +
+		`<dt>Version</dt><dd>1.0.0&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt;</dd>` +
+		`<dt>Git commit</dt><dd>2032d5b</dd>` +
+		`<dt>Git tree state</dt><dd>clean</dd>` +
+		`</dl>`)
+	if result := info.HTML(); result != expected {
+		t.Fatalf("expected %s but got %s", expected, result)
+	}
+}
+
+func TestServerInfo(t *testing.T) {
+	info := Info{Version: "1.2.0", GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123"}
+	expected := "myserver/1.2.0 (commit 2032d5b1a2b3)"
+	if result := info.serverInfo("myserver"); result != expected {
+		t.Fatalf("expected %q but got %q", expected, result)
+	}
+
+	info = Info{Version: "1.2.0\nInjected: header"}
+	expected = "myserver/1.2.0 Injected: header"
+	if result := info.serverInfo("myserver"); result != expected {
+		t.Fatalf("expected %q but got %q", expected, result)
+	}
+
+	info = Info{Version: strings.Repeat("1", 200), GitCommit: "2032d5b"}
+	result := info.serverInfo("myserver")
+	if len(result) != maxServerInfoLength || !strings.HasSuffix(result, "...") {
+		t.Fatalf("expected a truncated server info but got %q", result)
+	}
+
+	info = Info{Version: "1" + strings.Repeat("ü", 100)}
+	result = info.serverInfo("myserver")
+	if len(result) > maxServerInfoLength || !strings.HasSuffix(result, "...") || !utf8.ValidString(result) {
+		t.Fatalf("expected a server info truncated at a character boundary but got %q", result)
+	}
+
+	if result := (Info{}).ServerInfo(); result != programName() {
+		t.Fatalf("expected %q but got %q", programName(), result)
+	}
+
+	if result := unstampedInfo().serverInfo("myserver"); result != "myserver" {
+		t.Fatalf("expected no version or commit for a build without version information but got %q", result)
+	}
+}
+
+func TestMetricLabels(t *testing.T) {
+	info := Info{
+		Version:   "1.2.3+2032d5b-dirty",
+		GitCommit: "2032d5b",
+		GitBranch: "feature/metrics",
+		GoVersion: "go1.22.1 X:boringcrypto",
+	}
+	expected := map[string]string{
+		"version":   "1.2.3_2032d5b-dirty",
+		"revision":  "2032d5b",
+		"branch":    "feature_metrics",
+		"goversion": "go1.22.1_X_boringcrypto",
+	}
+	if labels := info.MetricLabels(); !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v but got %v", expected, labels)
+	}
+
+	info = unstampedInfo()
+	info.GitBranch = ""
+	info.GoVersion = "go1.22.1"
+	expected = map[string]string{
+		"version":   "",
+		"revision":  "",
+		"branch":    "",
+		"goversion": "go1.22.1",
+	}
+	if labels := info.MetricLabels(); !reflect.DeepEqual(labels, expected) {
+		t.Fatalf("expected %v for a build without version information but got %v", expected, labels)
+	}
+}
+
+func TestJournalFields(t *testing.T) {
+	// journal field names may contain only uppercase letters, digits and underscores
+	// and must not start with an underscore
+	journalField := regexp.MustCompile(`^[A-Z0-9][A-Z0-9_]*$`)
+
+	info := Info{
+		Version:   "1.2.3+2032d5b-dirty",
+		GitCommit: "2032d5b",
+		GoVersion: "go1.22.1",
+	}
+	expected := map[string]string{
+		"VERSION":    "1.2.3+2032d5b-dirty",
+		"GIT_COMMIT": "2032d5b",
+		"GO_VERSION": "go1.22.1",
+	}
+	fields := info.JournalFields()
+	if !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("expected %v but got %v", expected, fields)
+	}
+	for key := range fields {
+		if !journalField.MatchString(key) {
+			t.Fatalf("expected a valid journal field name but got %q", key)
+		}
+	}
+
+	info = unstampedInfo()
+	info.GoVersion = "go1.22.1"
+	expected = map[string]string{
+		"VERSION":    "",
+		"GIT_COMMIT": "",
+		"GO_VERSION": "go1.22.1",
+	}
+	if fields := info.JournalFields(); !reflect.DeepEqual(fields, expected) {
+		t.Fatalf("expected %v for a build without version information but got %v", expected, fields)
+	}
+}
+
+func TestGeneratedHeader(t *testing.T) {
+	// pattern of generated file comments recognized by Go tools
+	generated := regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+	var testCases = []struct {
+		info     Info
+		expected string
+	}{
+		{
+			Info{Version: "1.2.0", GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123"},
+			"// Code generated at version 1.2.0 (commit 2032d5b1a2b3) — DO NOT EDIT.",
+		},
+		{Info{Version: "1.2.0"}, "// Code generated at version 1.2.0 — DO NOT EDIT."},
+		{Info{}, "// Code generated — DO NOT EDIT."},
+		{unstampedInfo(), "// Code generated — DO NOT EDIT."},
+	}
+	for _, testCase := range testCases {
+		header := testCase.info.GeneratedHeader()
+		if header != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, header)
+		}
+		if !generated.MatchString(header) {
+			t.Fatalf("expected %q to be recognized as a generated file header", header)
+		}
+	}
+}
+
+func TestGoStyle(t *testing.T) {
+	runtimePlatform := runtime.GOOS + "/" + runtime.GOARCH
+	var testCases = []struct {
+		info     Info
+		expected string
+	}{
+		{
+			Info{Version: "v1.2.0", GoVersion: "go1.22.1", GoOS: "linux", GoArch: "arm64"},
+			"mytool version v1.2.0 go1.22.1 linux/arm64",
+		},
+		{Info{Version: "v1.2.0", GoVersion: "go1.22.1"}, "mytool version v1.2.0 go1.22.1 " + runtimePlatform},
+		{Info{GoVersion: "go1.22.1"}, "mytool version go1.22.1 " + runtimePlatform},
+		{Info{}, "mytool version " + runtimePlatform},
+		{unstampedInfo(), "mytool version " + runtime.Version() + " " + runtimePlatform},
+	}
+	for _, testCase := range testCases {
+		if result := testCase.info.goStyle("mytool"); result != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, result)
+		}
+	}
+
+	expected := programName() + " version "
+	if result := GoStyle(); !strings.HasPrefix(result, expected) || !strings.Contains(result, runtime.Version()) {
+		t.Fatalf("expected %q to start with %q and contain the Go version", result, expected)
+	}
+}
+
+func TestAuditEvent(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	var testCases = []struct {
+		info     Info
+		expected map[string]interface{}
+	}{
+		{
+			Info{Version: "1.2.0", GitCommit: "2032d5b", BuildUser: "builder", BuildHost: "ci-1"},
+			map[string]interface{}{
+				"type":      AuditEventType,
+				"timestamp": "2024-06-01T10:30:00Z",
+				"version":   "1.2.0",
+				"commit":    "2032d5b",
+				"builder":   "builder@ci-1",
+			},
+		},
+		{
+			Info{Version: "1.2.0", BuildHost: "ci-1"},
+			map[string]interface{}{
+				"type":      AuditEventType,
+				"timestamp": "2024-06-01T10:30:00Z",
+				"version":   "1.2.0",
+				"commit":    "",
+				"builder":   "ci-1",
+			},
+		},
+		{
+			unstampedInfo(),
+			map[string]interface{}{
+				"type":      AuditEventType,
+				"timestamp": "2024-06-01T10:30:00Z",
+				"version":   "",
+				"commit":    "",
+				"builder":   "",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		if event := testCase.info.auditEvent(now); !reflect.DeepEqual(event, testCase.expected) {
+			t.Fatalf("expected %v but got %v", testCase.expected, event)
+		}
+	}
+
+	event := (Info{}).AuditEvent()
+	for _, key := range []string{"type", "timestamp", "version", "commit", "builder"} {
+		if _, ok := event[key]; !ok {
+			t.Fatalf("expected field %q in %v", key, event)
+		}
+	}
+}
+
+func TestArtifactName(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.0", "mytool-1.2.0-linux-amd64"},
+		{"v1.2.0-rc.1", "mytool-v1.2.0-rc.1-linux-amd64"},
+		{"1.2.3+2032d5b1a2b3c4", "mytool-1.2.3-2032d5b1a2b3c4-linux-amd64"},
+		{"1.2.3+2032d5b1a2b3c4-dirty", "mytool-1.2.3-2032d5b1a2b3c4-dirty-linux-amd64"},
+		{"v1.0.0-dirty", "mytool-v1.0.0-dirty-linux-amd64"},
+		{`1.2.0"; filename=evil`, "mytool-1.2.0---filename-evil-linux-amd64"},
+		{"", "mytool-linux-amd64"},
+	}
+	for _, testCase := range testCases {
+		name := (Info{Version: testCase.version}).ArtifactName("mytool", "linux", "amd64")
+		if name != testCase.expected {
+			t.Fatalf("expected %q for version %q but got %q", testCase.expected, testCase.version, name)
+		}
+	}
+
+	expected := "mytool-linux-amd64"
+	if name := unstampedInfo().ArtifactName("mytool", "linux", "amd64"); name != expected {
+		t.Fatalf("expected %q for a build without version information but got %q", expected, name)
+	}
+}
+
+func TestBugReport(t *testing.T) {
+	info := Info{
+		Version:   "1.2.0",
+		GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123",
+		GoVersion: "go1.22.1",
+		GoOS:      "linux",
+		GoArch:    "amd64",
+		BuildHost: "ci-1",
+	}
+	expected := "```\n" +
+		"Version:    1.2.0\n" +
+		"Git commit: 2032d5b1a2b3c4d5e6f70123456789abcdef0123\n" +
+		"Go version: go1.22.1\n" +
+		"Platform:   linux/amd64\n" +
+		"```\n"
+	if report := info.BugReport(); report != expected {
+		t.Fatalf("expected %q but got %q", expected, report)
+	}
+
+	expected = "```\nVersion:    1.2.0\n```\n"
+	if report := (Info{Version: "1.2.0"}).BugReport(); report != expected {
+		t.Fatalf("expected %q but got %q", expected, report)
+	}
+
+	info = unstampedInfo()
+	info.GoVersion = "go1.22.1"
+	expected = "```\nGo version: go1.22.1\n```\n"
+	if report := info.BugReport(); report != expected {
+		t.Fatalf("expected %q for a build without version information but got %q", expected, report)
+	}
+}
+
+func TestAbout(t *testing.T) {
+	var testCases = []struct {
+		info     Info
+		expected string
+	}{
+		{
+			Info{
+				Version:   "1.2.0",
+				GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123",
+				BuildTime: "2024-06-01T10:30:00Z",
+				GoVersion: "go1.22.1",
+			},
+			"mytool\nVersion 1.2.0 (commit 2032d5b1a2b3)\nBuilt on 2024-06-01T10:30:00Z\nGo version go1.22.1",
+		},
+		{Info{Version: "1.2.0", GoVersion: "go1.22.1"}, "mytool\nVersion 1.2.0\nGo version go1.22.1"},
+		{Info{GitCommit: "2032d5b"}, "mytool\nCommit 2032d5b"},
+		{Info{}, "mytool"},
+		{unstampedInfo(), "mytool\nGo version " + runtime.Version()},
+	}
+	for _, testCase := range testCases {
+		if about := testCase.info.About("mytool"); about != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, about)
+		}
+	}
+}
+
+func TestStatusBar(t *testing.T) {
+	const commitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+
+	var testCases = []struct {
+		comment  string
+		info     Info
+		expected string
+	}{
+		{
+			comment:  "clean",
+			info:     stampedInfo(Info{Version: "v1.2.0", GitCommit: commitID, GitTreeState: "clean"}),
+			expected: "v1.2.0",
+		},
+		{
+			comment:  "dirty",
+			info:     stampedInfo(Info{Version: "v1.2.0-dirty", GitCommit: commitID, GitTreeState: "dirty"}),
+			expected: "v1.2.0*",
+		},
+		{
+			comment:  "build metadata",
+			info:     stampedInfo(Info{Version: "1.2.3+2032d5b1a2b3c4-dirty", GitCommit: commitID, GitTreeState: "dirty"}),
+			expected: "1.2.3*",
+		},
+		{
+			comment:  "default version",
+			info:     stampedInfo(Info{Version: "v0.0.0-master+$Format:%h$", GitCommit: commitID, GitTreeState: "clean"}),
+			expected: "2032d5b1a2b3",
+		},
+		{
+			comment:  "default version dirty",
+			info:     stampedInfo(Info{Version: "v0.0.0-master+$Format:%h$", GitCommit: commitID, GitTreeState: "dirty"}),
+			expected: "2032d5b1a2b3*",
+		},
+		{comment: "unstamped", info: unstampedInfo(), expected: ""},
+	}
+	for _, testCase := range testCases {
+		if status := testCase.info.StatusBar(); status != testCase.expected {
+			t.Fatalf("%v: expected %q but got %q", testCase.comment, testCase.expected, status)
+		}
+	}
+}
+
+func TestGoConst(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.0", `const appVersion = "1.2.0"`},
+		{`1.2.0"+injected`, `const appVersion = "1.2.0\"+injected"`},
+	}
+	for _, testCase := range testCases {
+		snippet, err := (Info{Version: testCase.version}).GoConst("appVersion")
+		if err != nil {
+			t.Fatalf("expected a declaration for version %q but got %v", testCase.version, err)
+		}
+		if snippet != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, snippet)
+		}
+		source := "package generated\n\n" + snippet + "\n"
+		formatted, err := format.Source([]byte(source))
+		if err != nil {
+			t.Fatalf("expected %q to compile but got %v", snippet, err)
+		}
+		if string(formatted) != source {
+			t.Fatalf("expected %q to be formatted but got %q", source, formatted)
+		}
+	}
+
+	expected := `const appVersion = ""`
+	if snippet, err := unstampedInfo().GoConst("appVersion"); err != nil || snippet != expected {
+		t.Fatalf("expected %q for a build without version information but got %q (%v)", expected, snippet, err)
+	}
+
+	for _, name := range []string{"", "1version", "app-version", "app.Version", "const", "func"} {
+		if snippet, err := (Info{Version: "1.2.0"}).GoConst(name); err == nil {
+			t.Fatalf("expected an error for name %q but got %q", name, snippet)
+		}
+	}
+}
+
+func TestCacheBust(t *testing.T) {
+	const commitID = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+	urlSafe := regexp.MustCompile(`^[A-Za-z0-9._~-]+$`)
+
+	var tokens = make(map[string]Info)
+	for _, info := range []Info{
+		{Version: "1.2.0", GitCommit: commitID},
+		{Version: "1.2.0", GitCommit: "a1b2c3d"},
+		{Version: "1.2.3+2032d5b/feature branch?&", GitCommit: commitID},
+		{GitCommit: commitID},
+	} {
+		token := info.CacheBust()
+		if !urlSafe.MatchString(token) {
+			t.Fatalf("expected a URL-safe token for %+v but got %q", info, token)
+		}
+		if token != info.CacheBust() {
+			t.Fatalf("expected a stable token for %+v", info)
+		}
+		if other, ok := tokens[token]; ok {
+			t.Fatalf("expected different tokens for %+v and %+v", info, other)
+		}
+		tokens[token] = info
+	}
+
+	if token := (Info{GitCommit: commitID}).CacheBust(); token != commitID[:shortCommitLength] {
+		t.Fatalf("expected the abbreviated commit for a build without version but got %q", token)
+	}
+	if token := (Info{GitCommit: defaultGitCommit}).CacheBust(); token != "" {
+		t.Fatalf("expected no token for an unknown build but got %q", token)
+	}
+	if token := unstampedInfo().CacheBust(); token != "" {
+		t.Fatalf("expected no token for a build without version information but got %q", token)
+	}
+	info := unstampedInfo()
+	info.GitCommit = commitID
+	if token := info.CacheBust(); token != commitID[:shortCommitLength] {
+		t.Fatalf("expected the abbreviated commit for a build with the default version but got %q", token)
+	}
+}
+
+// stampedInfo returns the version information of a build with info injected by the linker flags.
+func stampedInfo(info Info) Info {
+	defer saveVars()()
+	Set(info)
+	return Get()
+}
+
+// unstampedInfo returns the version information of a build without linker flags.
+func unstampedInfo() Info {
+	defer saveVars()()
+	resetVars()
+	return Get()
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+// LegacySymbols references the variables of a previously used version package
+// that linker flags of existing build scripts still set.
+// Nil references are ignored.
+type LegacySymbols struct {
+	Version      *string
+	GitCommit    *string
+	GitTreeState *string
+}
+
+// legacySymbols is the configured fallback for builds without version information
+var legacySymbols LegacySymbols
+
+// SetLegacySymbols configures Get to fall back to the variables referenced by symbols
+// if the build does not carry version information for this package.
+//
+// It is meant to ease the migration from another version package: keep the old variables
+// and register them during initialization, then switch the build scripts to linkflags and
+// remove the old variables once no build uses them:
+//
+//	package main
+//
+//	var (
+//		oldVersion string
+//		oldCommit  string
+//	)
+//
+//	func init() {
+//		version.SetLegacySymbols(version.LegacySymbols{Version: &oldVersion, GitCommit: &oldCommit})
+//	}
+func SetLegacySymbols(symbols LegacySymbols) {
+	legacySymbols = symbols
+}
+
+// legacyInfo returns info updated with the non-empty values of the legacy variables.
+func legacyInfo(info Info) Info {
+	if value := legacySymbols.Version; value != nil && *value != "" {
+		info.Version = *value
+	}
+	if value := legacySymbols.GitCommit; value != nil && *value != "" {
+		info.GitCommit = *value
+	}
+	if value := legacySymbols.GitTreeState; value != nil && *value != "" {
+		info.GitTreeState = *value
+	}
+	return info
+}
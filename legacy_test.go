@@ -0,0 +1,34 @@
+package version
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestLegacySymbols(t *testing.T) {
+	defer saveVars()()
+	defer SetLegacySymbols(LegacySymbols{})
+
+	var (
+		legacyVersion = "1.4.0"
+		legacyCommit  = "2032d5b1a2b3c4d5e6f70123456789abcdef0123"
+	)
+	SetLegacySymbols(LegacySymbols{Version: &legacyVersion, GitCommit: &legacyCommit})
+
+	// build without version information for this package
+	Set(Info{Version: "v0.0.1", GitCommit: defaultGitCommit, GitTreeState: "not a git tree"})
+	info := Get()
+	expected := Info{Version: legacyVersion, GitCommit: legacyCommit, GitTreeState: "not a git tree", GoVersion: runtime.Version(), ModulePath: mainModulePath("")}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, info)
+	}
+
+	// the injected values take precedence
+	Set(Info{Version: "1.5.0", GitCommit: "ab01cd", GitTreeState: "clean"})
+	info = Get()
+	expected = Info{Version: "1.5.0", GitCommit: "ab01cd", GitTreeState: "clean", GoVersion: runtime.Version(), ModulePath: mainModulePath("")}
+	if !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, info)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+// Pre-defined OCI image annotation keys.
+// See https://github.com/opencontainers/image-spec/blob/main/annotations.md
+const (
+	OCILabelVersion  = "org.opencontainers.image.version"
+	OCILabelRevision = "org.opencontainers.image.revision"
+	OCILabelCreated  = "org.opencontainers.image.created"
+)
+
+// FromOCILabels returns the version information given with the standard OCI annotations
+// of a container image, e.g. the labels of the image a process is running from.
+// The version, revision and creation time map to Version, GitCommit and BuildTime.
+// Other labels are ignored.
+func FromOCILabels(labels map[string]string) Info {
+	return Info{
+		Version:   labels[OCILabelVersion],
+		GitCommit: labels[OCILabelRevision],
+		BuildTime: labels[OCILabelCreated],
+	}
+}
@@ -0,0 +1,27 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromOCILabels(t *testing.T) {
+	labels := map[string]string{
+		"org.opencontainers.image.version":  "1.2.0",
+		"org.opencontainers.image.revision": "2032d5b1a2b3c4d5e6f70123456789abcdef0123",
+		"org.opencontainers.image.created":  "2024-06-01T10:30:00Z",
+		"org.opencontainers.image.source":   "https://github.com/gravitational/version",
+		"maintainer":                        "ops@example.com",
+	}
+	expected := Info{
+		Version:   "1.2.0",
+		GitCommit: "2032d5b1a2b3c4d5e6f70123456789abcdef0123",
+		BuildTime: "2024-06-01T10:30:00Z",
+	}
+	if info := FromOCILabels(labels); !reflect.DeepEqual(info, expected) {
+		t.Fatalf("expected %+v but got %+v", expected, info)
+	}
+	if info := FromOCILabels(nil); !reflect.DeepEqual(info, Info{}) {
+		t.Fatalf("expected empty version information but got %+v", info)
+	}
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+// Package versionpb provides the version information as a protocol buffers message
+// (see version.proto) without depending on a protocol buffers runtime.
+package versionpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gravitational/version"
+)
+
+// Submodule is the protocol buffers message for version.SubmoduleInfo.
+type Submodule struct {
+	Path   string
+	Commit string
+}
+
+// VersionInfo is the protocol buffers message for version.Info.
+type VersionInfo struct {
+	Version         string
+	GitCommit       string
+	GitTreeState    string
+	GitBranch       string
+	GoOS            string
+	GoArch          string
+	Platform        string
+	SourceTreeHash  string
+	CommitTime      string
+	BuildTime       string
+	BuildTimeSource string
+	BuildUser       string
+	BuildHost       string
+	GoVersion       string
+	Submodules      []Submodule
+	Dirty           bool
+	Broken          bool
+	Edition         string
+	ModulePath      string
+	VersionMajor    string
+	VersionMinor    string
+	VersionPatch    string
+	Attrs           map[string]string
+}
+
+// ToProto converts info to its protocol buffers message.
+func ToProto(info version.Info) *VersionInfo {
+	result := &VersionInfo{
+		Version:         info.Version,
+		GitCommit:       info.GitCommit,
+		GitTreeState:    info.GitTreeState,
+		GitBranch:       info.GitBranch,
+		GoOS:            info.GoOS,
+		GoArch:          info.GoArch,
+		Platform:        info.BuildPlatform,
+		SourceTreeHash:  info.SourceTreeHash,
+		CommitTime:      info.CommitTime,
+		BuildTime:       info.BuildTime,
+		BuildTimeSource: info.BuildTimeSource,
+		BuildUser:       info.BuildUser,
+		BuildHost:       info.BuildHost,
+		GoVersion:       info.GoVersion,
+		Dirty:           info.Dirty,
+		Broken:          info.Broken,
+		Edition:         info.Edition,
+		ModulePath:      info.ModulePath,
+		VersionMajor:    info.VersionMajor,
+		VersionMinor:    info.VersionMinor,
+		VersionPatch:    info.VersionPatch,
+	}
+	if len(info.Attrs) != 0 {
+		result.Attrs = make(map[string]string, len(info.Attrs))
+		for key, value := range info.Attrs {
+			result.Attrs[key] = value
+		}
+	}
+	for _, submodule := range info.Submodules {
+		result.Submodules = append(result.Submodules, Submodule{Path: submodule.Path, Commit: submodule.Commit})
+	}
+	return result
+}
+
+// FromProto converts the protocol buffers message m to version information.
+func FromProto(m *VersionInfo) version.Info {
+	info := version.Info{
+		Version:         m.Version,
+		GitCommit:       m.GitCommit,
+		GitTreeState:    m.GitTreeState,
+		GitBranch:       m.GitBranch,
+		GoOS:            m.GoOS,
+		GoArch:          m.GoArch,
+		BuildPlatform:   m.Platform,
+		SourceTreeHash:  m.SourceTreeHash,
+		CommitTime:      m.CommitTime,
+		BuildTime:       m.BuildTime,
+		BuildTimeSource: m.BuildTimeSource,
+		BuildUser:       m.BuildUser,
+		BuildHost:       m.BuildHost,
+		GoVersion:       m.GoVersion,
+		Dirty:           m.Dirty,
+		Broken:          m.Broken,
+		Edition:         m.Edition,
+		ModulePath:      m.ModulePath,
+		VersionMajor:    m.VersionMajor,
+		VersionMinor:    m.VersionMinor,
+		VersionPatch:    m.VersionPatch,
+	}
+	if len(m.Attrs) != 0 {
+		info.Attrs = make(map[string]string, len(m.Attrs))
+		for key, value := range m.Attrs {
+			info.Attrs[key] = value
+		}
+	}
+	for _, submodule := range m.Submodules {
+		info.Submodules = append(info.Submodules, version.SubmoduleInfo{Path: submodule.Path, Commit: submodule.Commit})
+	}
+	return info
+}
+
+// Field numbers of VersionInfo and Submodule as defined in version.proto
+const (
+	fieldSubmodules = 15
+	fieldDirty      = 16
+	fieldBroken     = 17
+	fieldAttrs      = 23
+	lastField       = 23
+
+	fieldSubmodulePath   = 1
+	fieldSubmoduleCommit = 2
+
+	// fields of the entries of map fields
+	fieldEntryKey   = 1
+	fieldEntryValue = 2
+)
+
+// Wire types of the protocol buffers encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// stringFields maps the field numbers of the string fields of the message to their values.
+func (r *VersionInfo) stringFields() map[uint64]*string {
+	return map[uint64]*string{
+		1:  &r.Version,
+		2:  &r.GitCommit,
+		3:  &r.GitTreeState,
+		4:  &r.GitBranch,
+		5:  &r.GoOS,
+		6:  &r.GoArch,
+		7:  &r.Platform,
+		8:  &r.SourceTreeHash,
+		9:  &r.CommitTime,
+		10: &r.BuildTime,
+		11: &r.BuildTimeSource,
+		12: &r.BuildUser,
+		13: &r.BuildHost,
+		14: &r.GoVersion,
+		18: &r.Edition,
+		19: &r.ModulePath,
+		20: &r.VersionMajor,
+		21: &r.VersionMinor,
+		22: &r.VersionPatch,
+	}
+}
+
+// Marshal encodes the message in the protocol buffers wire format.
+// Fields are encoded in the order of their numbers and fields with default values are omitted.
+func (r *VersionInfo) Marshal() []byte {
+	var buf []byte
+	fields := r.stringFields()
+	for number := uint64(1); number <= lastField; number++ {
+		switch number {
+		case fieldSubmodules:
+			for _, submodule := range r.Submodules {
+				buf = appendBytes(buf, number, submodule.marshal())
+			}
+		case fieldDirty:
+			buf = appendBool(buf, number, r.Dirty)
+		case fieldBroken:
+			buf = appendBool(buf, number, r.Broken)
+		case fieldAttrs:
+			keys := make([]string, 0, len(r.Attrs))
+			for key := range r.Attrs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				buf = appendBytes(buf, number, marshalEntry(key, r.Attrs[key]))
+			}
+		default:
+			if value := *fields[number]; value != "" {
+				buf = appendBytes(buf, number, []byte(value))
+			}
+		}
+	}
+	return buf
+}
+
+// Unmarshal decodes the message from data in the protocol buffers wire format.
+// Unknown fields are skipped and known fields with an unexpected wire type are an error.
+func (r *VersionInfo) Unmarshal(data []byte) error {
+	*r = VersionInfo{}
+	fields := r.stringFields()
+	return decode(data, func(number, wireType uint64, value []byte, varint uint64) error {
+		switch number {
+		case fieldDirty, fieldBroken:
+			if err := checkWireType(number, wireType, wireVarint); err != nil {
+				return err
+			}
+		case fieldSubmodules, fieldAttrs:
+			if err := checkWireType(number, wireType, wireBytes); err != nil {
+				return err
+			}
+		default:
+			if _, ok := fields[number]; ok {
+				if err := checkWireType(number, wireType, wireBytes); err != nil {
+					return err
+				}
+			}
+		}
+		switch number {
+		case fieldSubmodules:
+			var submodule Submodule
+			if err := submodule.unmarshal(value); err != nil {
+				return err
+			}
+			r.Submodules = append(r.Submodules, submodule)
+		case fieldDirty:
+			r.Dirty = varint != 0
+		case fieldBroken:
+			r.Broken = varint != 0
+		case fieldAttrs:
+			key, value, err := unmarshalEntry(value)
+			if err != nil {
+				return err
+			}
+			if r.Attrs == nil {
+				r.Attrs = make(map[string]string)
+			}
+			r.Attrs[key] = value
+		default:
+			if field, ok := fields[number]; ok {
+				*field = string(value)
+			}
+		}
+		return nil
+	})
+}
+
+func (r Submodule) marshal() []byte {
+	var buf []byte
+	if r.Path != "" {
+		buf = appendBytes(buf, fieldSubmodulePath, []byte(r.Path))
+	}
+	if r.Commit != "" {
+		buf = appendBytes(buf, fieldSubmoduleCommit, []byte(r.Commit))
+	}
+	return buf
+}
+
+func (r *Submodule) unmarshal(data []byte) error {
+	return decode(data, func(number, wireType uint64, value []byte, varint uint64) error {
+		switch number {
+		case fieldSubmodulePath:
+			r.Path = string(value)
+		case fieldSubmoduleCommit:
+			r.Commit = string(value)
+		default:
+			return nil
+		}
+		return checkWireType(number, wireType, wireBytes)
+	})
+}
+
+// marshalEntry encodes an entry of a map field with string keys and values.
+func marshalEntry(key, value string) []byte {
+	var buf []byte
+	if key != "" {
+		buf = appendBytes(buf, fieldEntryKey, []byte(key))
+	}
+	if value != "" {
+		buf = appendBytes(buf, fieldEntryValue, []byte(value))
+	}
+	return buf
+}
+
+// unmarshalEntry decodes an entry of a map field with string keys and values.
+func unmarshalEntry(data []byte) (key, value string, err error) {
+	err = decode(data, func(number, wireType uint64, field []byte, varint uint64) error {
+		switch number {
+		case fieldEntryKey:
+			key = string(field)
+		case fieldEntryValue:
+			value = string(field)
+		default:
+			return nil
+		}
+		return checkWireType(number, wireType, wireBytes)
+	})
+	return key, value, err
+}
+
+// checkWireType returns an error if the known field number has wireType instead of expected.
+func checkWireType(number, wireType, expected uint64) error {
+	if wireType != expected {
+		return fmt.Errorf("unexpected wire type %v of field %v: expected %v", wireType, number, expected)
+	}
+	return nil
+}
+
+func appendBytes(buf []byte, number uint64, value []byte) []byte {
+	buf = binary.AppendUvarint(buf, number<<3|wireBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+func appendBool(buf []byte, number uint64, value bool) []byte {
+	if !value {
+		return buf
+	}
+	buf = binary.AppendUvarint(buf, number<<3|wireVarint)
+	return binary.AppendUvarint(buf, 1)
+}
+
+// errTruncated is returned for messages that end in the middle of a field
+var errTruncated = errors.New("truncated message")
+
+// decode invokes field for each field of the message encoded in data
+// with the field number, the wire type and either the value of a length-delimited field
+// or the value of a varint field.
+func decode(data []byte, field func(number, wireType uint64, value []byte, varint uint64) error) error {
+	for len(data) != 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errTruncated
+		}
+		data = data[n:]
+		number, wireType := key>>3, key&7
+		var value []byte
+		var varint uint64
+		switch wireType {
+		case wireVarint:
+			if varint, n = binary.Uvarint(data); n <= 0 {
+				return errTruncated
+			}
+			data = data[n:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errTruncated
+			}
+			value, data = data[n:n+int(length)], data[n+int(length):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if wireType == wireFixed32 {
+				size = 4
+			}
+			if len(data) < size {
+				return errTruncated
+			}
+			data = data[size:]
+		default:
+			return fmt.Errorf("unsupported wire type %v of field %v", wireType, number)
+		}
+		if err := field(number, wireType, value, varint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
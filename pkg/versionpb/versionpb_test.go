@@ -0,0 +1,103 @@
+package versionpb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gravitational/version"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var testCases = []version.Info{
+		{},
+		{Version: "1.2.0"},
+		{
+			Version:         "1.2.3+2032d5b1a2b3c4-dirty",
+			GitCommit:       "2032d5b1a2b3c4d5e6f70123456789abcdef0123",
+			GitTreeState:    "dirty",
+			GitBranch:       "feature/ünicode",
+			GoOS:            "linux",
+			GoArch:          "amd64",
+			BuildPlatform:   "linux/amd64",
+			SourceTreeHash:  "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+			CommitTime:      "2024-06-01T10:30:00Z",
+			BuildTime:       "2024-06-01T11:00:00Z",
+			BuildTimeSource: version.BuildTimeSourceEpoch,
+			BuildUser:       "builder",
+			BuildHost:       "ci-1",
+			GoVersion:       "go1.22.1",
+			Submodules: []version.SubmoduleInfo{
+				{Path: "vendor/lib", Commit: "3f2a1b9"},
+				{Path: "docs", Commit: "a1b2c3d"},
+			},
+			Dirty:        true,
+			Broken:       true,
+			Edition:      version.EditionEnterprise,
+			ModulePath:   "github.com/example/app",
+			VersionMajor: "1",
+			VersionMinor: "2",
+			VersionPatch: "3",
+			Attrs:        map[string]string{"tracking-id": "42", "note": "it's a b", "empty": ""},
+		},
+	}
+	for _, info := range testCases {
+		var decoded VersionInfo
+		if err := decoded.Unmarshal(ToProto(info).Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		if result := FromProto(&decoded); !reflect.DeepEqual(result, info) {
+			t.Fatalf("expected %+v but got %+v", info, result)
+		}
+	}
+}
+
+func TestWireFormat(t *testing.T) {
+	m := &VersionInfo{
+		Version:    "1.2.0",
+		Submodules: []Submodule{{Path: "lib"}},
+		Dirty:      true,
+		Attrs:      map[string]string{"b": "2", "a": "1"},
+	}
+	expected := []byte{
+		0x0a, 5, '1', '.', '2', '.', '0', // version = 1
+		0x7a, 5, 0x0a, 3, 'l', 'i', 'b', // submodules = 15 with path = 1
+		0x80, 0x01, 1, // dirty = 16
+		0xba, 0x01, 6, 0x0a, 1, 'a', 0x12, 1, '1', // attrs = 23 with key = 1 and value = 2 in key order
+		0xba, 0x01, 6, 0x0a, 1, 'b', 0x12, 1, '2',
+	}
+	if data := m.Marshal(); !bytes.Equal(data, expected) {
+		t.Fatalf("expected %x but got %x", expected, data)
+	}
+
+	// unknown varint, fixed64, fixed32 and length-delimited fields are skipped
+	unknown := []byte{0xf8, 0x01, 42, 0xc1, 0x01, 1, 2, 3, 4, 5, 6, 7, 8, 0xcd, 0x01, 1, 2, 3, 4, 0xd2, 0x01, 1, 'x'}
+	var decoded VersionInfo
+	if err := decoded.Unmarshal(append(unknown, expected...)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, m) {
+		t.Fatalf("expected %+v but got %+v", m, decoded)
+	}
+
+	for _, data := range [][]byte{{0x0a}, {0x0a, 5, '1'}, {0x80}, {0xc1, 0x01, 1}} {
+		if err := decoded.Unmarshal(data); err == nil {
+			t.Fatalf("expected an error for truncated message %x", data)
+		}
+	}
+
+	// known fields with an unexpected wire type are rejected
+	for _, data := range [][]byte{
+		{0x08, 1},                // version = 1 as varint
+		{0x15, 1, 2, 3, 4},       // git commit = 2 as fixed32
+		{0x82, 0x01, 1, 'x'},     // dirty = 16 as length-delimited
+		{0x7a, 2, 0x08, 1},       // submodules = 15 with path = 1 as varint
+		{0xba, 0x01, 2, 0x10, 1}, // attrs = 23 with value = 2 as varint
+		{0xb8, 0x01, 1},          // attrs = 23 as varint
+	} {
+		if err := decoded.Unmarshal(data); err == nil || !strings.Contains(err.Error(), "unexpected wire type") {
+			t.Fatalf("expected an unexpected wire type error for %x but got %v", data, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"fmt"
+	"sync"
+)
+
+// schemaVersions maps application versions to the versions of the schema
+// they have introduced
+var schemaVersions = struct {
+	sync.Mutex
+	versions map[string]string
+}{versions: make(map[string]string)}
+
+// RegisterSchemaVersion records that application version appVersion
+// uses schema version schemaVersion.
+// The schema version applies to all later application versions until
+// the next registered application version.
+func RegisterSchemaVersion(appVersion, schemaVersion string) {
+	schemaVersions.Lock()
+	defer schemaVersions.Unlock()
+	schemaVersions.versions[appVersion] = schemaVersion
+}
+
+// SchemaVersion returns the schema version of the running application:
+// the schema version registered for the highest application version
+// that is not higher than the current build version.
+func SchemaVersion() (string, error) {
+	current := Get().Version
+	if _, err := ParseSemver(current); err != nil {
+		return "", err
+	}
+	schemaVersions.Lock()
+	defer schemaVersions.Unlock()
+	appVersions := make([]string, 0, len(schemaVersions.versions))
+	for appVersion := range schemaVersions.versions {
+		appVersions = append(appVersions, appVersion)
+	}
+	appVersion, ok := ClosestLower(current, appVersions)
+	if !ok {
+		return "", fmt.Errorf("no schema version registered for version %v", current)
+	}
+	return schemaVersions.versions[appVersion], nil
+}
@@ -0,0 +1,43 @@
+package version
+
+import "testing"
+
+func TestSchemaVersion(t *testing.T) {
+	defer saveVars()()
+	defer func(versions map[string]string) { schemaVersions.versions = versions }(schemaVersions.versions)
+	schemaVersions.versions = make(map[string]string)
+
+	RegisterSchemaVersion("1.0.0", "1")
+	RegisterSchemaVersion("1.3.0", "2")
+	RegisterSchemaVersion("v2.0.0", "3")
+
+	var testCases = []struct {
+		version  string
+		expected string
+	}{
+		{"1.0.0", "1"},
+		{"1.2.9", "1"},
+		{"1.3.0", "2"},
+		{"1.3.5+2032d5b1a2b3c4", "2"},
+		{"2.0.0-rc.1", "2"},
+		{"v2.0.0", "3"},
+		{"3.1.0", "3"},
+	}
+	for _, testCase := range testCases {
+		Set(Info{Version: testCase.version})
+		schemaVersion, err := SchemaVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if schemaVersion != testCase.expected {
+			t.Fatalf("expected schema version %v for %v but got %v", testCase.expected, testCase.version, schemaVersion)
+		}
+	}
+
+	for _, version := range []string{"0.9.0", "invalid"} {
+		Set(Info{Version: version})
+		if _, err := SchemaVersion(); err == nil {
+			t.Fatalf("expected an error for version %v", version)
+		}
+	}
+}
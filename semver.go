@@ -0,0 +1,377 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Semver is a version in semantic versioning format (http://semver.org).
+type Semver struct {
+	Major      int64
+	Minor      int64
+	Patch      int64
+	Prerelease string
+	Metadata   string
+}
+
+// ParseSemver parses version into a Semver.
+// An optional `v` prefix (as commonly used with git tags) is ignored.
+func ParseSemver(version string) (*Semver, error) {
+	v := strings.TrimPrefix(version, "v")
+	var result Semver
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		result.Metadata = v[i+1:]
+		if !validIdentifiers(result.Metadata, false) {
+			return nil, fmt.Errorf("invalid build metadata in version %q", version)
+		}
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		result.Prerelease = v[i+1:]
+		if !validIdentifiers(result.Prerelease, true) {
+			return nil, fmt.Errorf("invalid prerelease in version %q", version)
+		}
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid semantic version %q", version)
+	}
+	components := []*int64{&result.Major, &result.Minor, &result.Patch}
+	for i, part := range parts {
+		if !isNumeric(part) || (len(part) > 1 && part[0] == '0') {
+			return nil, fmt.Errorf("invalid semantic version %q", version)
+		}
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semantic version %q: %v", version, err)
+		}
+		*components[i] = value
+	}
+	return &result, nil
+}
+
+// String returns the version in canonical format without a `v` prefix.
+func (r Semver) String() string {
+	result := fmt.Sprintf("%d.%d.%d", r.Major, r.Minor, r.Patch)
+	if r.Prerelease != "" {
+		result += "-" + r.Prerelease
+	}
+	if r.Metadata != "" {
+		result += "+" + r.Metadata
+	}
+	return result
+}
+
+// Compare compares this version to other using semver precedence rules.
+// It returns -1, 0 or 1 if this version is respectively lower than, equal to or
+// higher than other. Build metadata does not affect precedence.
+func (r Semver) Compare(other Semver) int {
+	if result := compareInt(r.Major, other.Major); result != 0 {
+		return result
+	}
+	if result := compareInt(r.Minor, other.Minor); result != 0 {
+		return result
+	}
+	if result := compareInt(r.Patch, other.Patch); result != 0 {
+		return result
+	}
+	return comparePrerelease(r.Prerelease, other.Prerelease)
+}
+
+// Compare parses and compares versions a and b.
+// See Semver.Compare for details.
+func Compare(a, b string) (int, error) {
+	left, err := ParseSemver(a)
+	if err != nil {
+		return 0, err
+	}
+	right, err := ParseSemver(b)
+	if err != nil {
+		return 0, err
+	}
+	return left.Compare(*right), nil
+}
+
+// comparePrerelease compares prerelease parts a and b.
+// A version without prerelease has higher precedence than one with it.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	left := strings.Split(a, ".")
+	right := strings.Split(b, ".")
+	for i := 0; i < len(left) && i < len(right); i++ {
+		if result := compareIdentifier(left[i], right[i]); result != 0 {
+			return result
+		}
+	}
+	return compareInt(int64(len(left)), int64(len(right)))
+}
+
+// compareIdentifier compares individual prerelease identifiers a and b.
+// Numeric identifiers are compared numerically and always have lower precedence
+// than alphanumeric ones.
+func compareIdentifier(a, b string) int {
+	numericA, numericB := isNumeric(a), isNumeric(b)
+	switch {
+	case numericA && numericB:
+		if result := compareInt(int64(len(a)), int64(len(b))); result != 0 {
+			return result
+		}
+		return strings.Compare(a, b)
+	case numericA:
+		return -1
+	case numericB:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// validIdentifiers determines if value is a dot-separated list of valid semver identifiers.
+// If prerelease is true, numeric identifiers must not have leading zeroes.
+func validIdentifiers(value string, prerelease bool) bool {
+	for _, identifier := range strings.Split(value, ".") {
+		if identifier == "" {
+			return false
+		}
+		for _, c := range identifier {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+		if prerelease && isNumeric(identifier) && len(identifier) > 1 && identifier[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+func isNumeric(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ClosestLower returns the highest of candidates that is lower than or equal to target.
+// Candidates that are not valid semantic versions are ignored.
+// It returns false if target is invalid or no suitable candidate has been found.
+func ClosestLower(target string, candidates []string) (string, bool) {
+	targetVersion, err := ParseSemver(target)
+	if err != nil {
+		return "", false
+	}
+	var result string
+	var closest *Semver
+	for _, candidate := range candidates {
+		candidateVersion, err := ParseSemver(candidate)
+		if err != nil {
+			continue
+		}
+		if candidateVersion.Compare(*targetVersion) > 0 {
+			continue
+		}
+		if closest == nil || candidateVersion.Compare(*closest) > 0 {
+			result, closest = candidate, candidateVersion
+		}
+	}
+	return result, closest != nil
+}
+
+// Limits of the version components encoded by VersionKey
+const (
+	versionKeyMaxMinor = 999
+	versionKeyMaxPatch = 999
+	versionKeyMaxMajor = (math.MaxInt64 - versionKeyMaxMinor*1000 - versionKeyMaxPatch) / 1000000
+)
+
+// VersionKey encodes version v as an integer that sorts the same as the version
+// for storage in systems that only handle integers:
+//
+//	major*1_000_000 + minor*1_000 + patch
+//
+// Prerelease and build metadata are ignored, hence `1.0.0-rc.1` and `1.0.0` share the same key.
+// Minor and patch components must not exceed 999 and the major component must not exceed
+// 9223372036853 so that the key fits into int64.
+func VersionKey(v string) (int64, error) {
+	parsed, err := ParseSemver(v)
+	if err != nil {
+		return 0, err
+	}
+	if parsed.Major > versionKeyMaxMajor || parsed.Minor > versionKeyMaxMinor || parsed.Patch > versionKeyMaxPatch {
+		return 0, fmt.Errorf("version %q is out of range: minor and patch must not exceed %v and major must not exceed %v",
+			v, versionKeyMaxMinor, versionKeyMaxMajor)
+	}
+	return parsed.Major*1000000 + parsed.Minor*1000 + parsed.Patch, nil
+}
+
+// Kinds of steps between two versions as returned by StepKind
+const (
+	StepMajor      = "major"
+	StepMinor      = "minor"
+	StepPatch      = "patch"
+	StepPrerelease = "prerelease"
+	StepNone       = "none"
+	StepDowngrade  = "downgrade"
+)
+
+// StepKind describes the nature of the step from version from to version to.
+// It returns the most significant version component that has changed (one of
+// StepMajor, StepMinor, StepPatch or StepPrerelease), StepNone if the versions
+// have the same precedence or StepDowngrade if to is lower than from.
+func StepKind(from, to string) (string, error) {
+	fromVersion, err := ParseSemver(from)
+	if err != nil {
+		return "", err
+	}
+	toVersion, err := ParseSemver(to)
+	if err != nil {
+		return "", err
+	}
+	switch result := toVersion.Compare(*fromVersion); {
+	case result < 0:
+		return StepDowngrade, nil
+	case result == 0:
+		return StepNone, nil
+	}
+	switch {
+	case toVersion.Major != fromVersion.Major:
+		return StepMajor, nil
+	case toVersion.Minor != fromVersion.Minor:
+		return StepMinor, nil
+	case toVersion.Patch != fromVersion.Patch:
+		return StepPatch, nil
+	}
+	return StepPrerelease, nil
+}
+
+// Canonicalize normalizes version v to canonical semver format
+// `major.minor.patch[-prerelease][+metadata]`.
+// It removes the `v` prefix and surrounding whitespace and fills in
+// missing minor and patch components with zeroes, e.g. `v1.2-rc.1` becomes `1.2.0-rc.1`.
+func Canonicalize(v string) (string, error) {
+	version := strings.TrimSpace(v)
+	version = strings.TrimPrefix(strings.TrimPrefix(version, "v"), "V")
+	core, suffix := version, ""
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		core, suffix = version[:i], version[i:]
+	}
+	switch strings.Count(core, ".") {
+	case 0:
+		core += ".0.0"
+	case 1:
+		core += ".0"
+	}
+	parsed, err := ParseSemver(core + suffix)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %q", v)
+	}
+	return parsed.String(), nil
+}
+
+// ValidateBump verifies that version target is exactly one increment above version current:
+// the next major, minor or patch version of current or a prerelease of one of these.
+// If current is a prerelease, only the release it prepares and higher prereleases of it
+// are accepted: any other version would skip the release (e.g. 1.3.0-rc.1 to 1.3.1 skips 1.3.0).
+// Build metadata is ignored.
+func ValidateBump(current, target string) error {
+	currentVersion, err := ParseSemver(current)
+	if err != nil {
+		return err
+	}
+	targetVersion, err := ParseSemver(target)
+	if err != nil {
+		return err
+	}
+	if targetVersion.Compare(*currentVersion) <= 0 {
+		return fmt.Errorf("version %v is not higher than %v", target, current)
+	}
+	core := Semver{Major: targetVersion.Major, Minor: targetVersion.Minor, Patch: targetVersion.Patch}
+	if currentVersion.Prerelease != "" {
+		prepared := Semver{Major: currentVersion.Major, Minor: currentVersion.Minor, Patch: currentVersion.Patch}
+		if core != prepared {
+			return fmt.Errorf("version %v skips release %v of prerelease %v", target, prepared, current)
+		}
+		// release or a later prerelease of the current prerelease
+		return nil
+	}
+	next := []Semver{
+		{Major: currentVersion.Major + 1},
+		{Major: currentVersion.Major, Minor: currentVersion.Minor + 1},
+		{Major: currentVersion.Major, Minor: currentVersion.Minor, Patch: currentVersion.Patch + 1},
+	}
+	for _, candidate := range next {
+		if core == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("version %v skips versions after %v: expected one of %v, %v or %v",
+		target, current, next[0], next[1], next[2])
+}
+
+// NightlyVersion returns the version of a nightly build of the release line baseMajorMinor
+// (e.g. `1.3` or `v1.3`) made on date with commitCount commits since the last release:
+// a prerelease of the form `1.3.0-nightly.20240601.42`.
+func NightlyVersion(baseMajorMinor string, date time.Time, commitCount int) (string, error) {
+	base, err := ParseSemver(baseMajorMinor + ".0")
+	if err != nil {
+		return "", fmt.Errorf("invalid release line %q: expected major.minor", baseMajorMinor)
+	}
+	if commitCount < 0 {
+		return "", fmt.Errorf("invalid commit count %v", commitCount)
+	}
+	base.Prerelease = fmt.Sprintf("nightly.%v.%v", date.UTC().Format("20060102"), commitCount)
+	return base.String(), nil
+}
+
+// SanitizeVersion trims surrounding whitespace from version v and verifies that
+// it only consists of printable ASCII characters.
+// Control and non-ASCII characters are rejected as they corrupt linker flags and displays.
+func SanitizeVersion(v string) (string, error) {
+	result := strings.TrimSpace(v)
+	for i := 0; i < len(result); i++ {
+		if c := result[i]; c < ' ' || c > '~' {
+			return "", fmt.Errorf("invalid character %q at offset %v in version %q", c, i, v)
+		}
+	}
+	return result, nil
+}
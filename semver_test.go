@@ -0,0 +1,305 @@
+package version
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSemver(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected Semver
+	}{
+		{"1.2.3", Semver{Major: 1, Minor: 2, Patch: 3}},
+		{"v0.10.0", Semver{Major: 0, Minor: 10, Patch: 0}},
+		{"1.0.0-alpha.1", Semver{Major: 1, Prerelease: "alpha.1"}},
+		{"3.13.3+2032d5b1a2b3c4", Semver{Major: 3, Minor: 13, Patch: 3, Metadata: "2032d5b1a2b3c4"}},
+		{"v1.0.0-rc.1+sha-dirty", Semver{Major: 1, Prerelease: "rc.1", Metadata: "sha-dirty"}},
+	}
+	for _, testCase := range testCases {
+		result, err := ParseSemver(testCase.version)
+		if err != nil {
+			t.Fatalf("failed to parse %v: %v", testCase.version, err)
+		}
+		if *result != testCase.expected {
+			t.Fatalf("expected %+v but got %+v", testCase.expected, *result)
+		}
+	}
+
+	for _, version := range []string{"", "1.2", "1.2.3.4", "01.2.3", "1.2.3-01", "1.2.3-", "1.2.3+a..b", "v0.0.0-master+$Format:%h$"} {
+		if _, err := ParseSemver(version); err == nil {
+			t.Fatalf("expected an error parsing %q", version)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// versions are listed in increasing order of precedence
+	versions := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	for i := range versions {
+		for j := range versions {
+			result, err := Compare(versions[i], versions[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := compareInt(int64(i), int64(j)); result != expected {
+				t.Fatalf("expected %v when comparing %v to %v but got %v", expected, versions[i], versions[j], result)
+			}
+		}
+	}
+
+	result, err := Compare("v1.0.0+abc", "1.0.0+def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != 0 {
+		t.Fatalf("expected build metadata to be ignored but got %v", result)
+	}
+}
+
+func TestClosestLower(t *testing.T) {
+	var testCases = []struct {
+		target     string
+		candidates []string
+		expected   string
+		found      bool
+	}{
+		{"1.5.0", []string{"1.0.0", "1.4.2", "2.0.0", "1.4.10"}, "1.4.10", true},
+		{"v1.4.2", []string{"1.0.0", "v1.4.2", "2.0.0"}, "v1.4.2", true},
+		{"1.0.0", []string{"1.0.0-rc.1", "1.0.0-beta.2"}, "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", []string{"1.0.0", "0.9.0"}, "0.9.0", true},
+		{"1.2.0", []string{"bogus", "1.1", "1.1.0"}, "1.1.0", true},
+		{"0.1.0", []string{"1.0.0", "2.0.0"}, "", false},
+		{"1.0.0", nil, "", false},
+		{"invalid", []string{"1.0.0"}, "", false},
+	}
+	for _, testCase := range testCases {
+		result, found := ClosestLower(testCase.target, testCase.candidates)
+		if result != testCase.expected || found != testCase.found {
+			t.Fatalf("expected (%q, %v) for %v in %q but got (%q, %v)",
+				testCase.expected, testCase.found, testCase.target, testCase.candidates, result, found)
+		}
+	}
+}
+
+func TestVersionKey(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected int64
+	}{
+		{"0.0.0", 0},
+		{"0.0.1", 1},
+		{"1.2.3", 1002003},
+		{"v12.999.999", 12999999},
+		{"1.2.3-rc.1+abc", 1002003},
+		{"9223372036853.999.999", 9223372036853999999},
+	}
+	for _, testCase := range testCases {
+		key, err := VersionKey(testCase.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != testCase.expected {
+			t.Fatalf("expected key %v for %v but got %v", testCase.expected, testCase.version, key)
+		}
+	}
+
+	for _, version := range []string{"1.1000.0", "1.0.1000", "9223372036854.0.0", "invalid"} {
+		if _, err := VersionKey(version); err == nil {
+			t.Fatalf("expected an error for %v", version)
+		}
+	}
+
+	// keys must sort the same as versions
+	lower, _ := VersionKey("1.9.999")
+	higher, _ := VersionKey("1.10.0")
+	if lower >= higher {
+		t.Fatalf("expected %v to be lower than %v", lower, higher)
+	}
+}
+
+func TestStepKind(t *testing.T) {
+	var testCases = []struct {
+		from     string
+		to       string
+		expected string
+	}{
+		{"1.2.3", "2.0.0", StepMajor},
+		{"1.2.3", "v2.1.0-beta.1", StepMajor},
+		{"1.2.3", "1.3.0", StepMinor},
+		{"1.2.3", "1.2.4", StepPatch},
+		{"1.2.3-alpha.1", "1.2.3-beta.1", StepPrerelease},
+		{"1.2.3-rc.1", "1.2.3", StepPrerelease},
+		{"1.2.3", "1.2.3", StepNone},
+		{"1.2.3+abc", "1.2.3+def", StepNone},
+		{"1.2.3", "1.2.2", StepDowngrade},
+		{"2.0.0", "1.9.9", StepDowngrade},
+		{"1.2.3", "1.2.3-rc.1", StepDowngrade},
+	}
+	for _, testCase := range testCases {
+		kind, err := StepKind(testCase.from, testCase.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kind != testCase.expected {
+			t.Fatalf("expected %v step from %v to %v but got %v", testCase.expected, testCase.from, testCase.to, kind)
+		}
+	}
+
+	if _, err := StepKind("1.0", "1.0.0"); err == nil {
+		t.Fatal("expected an error for an invalid version")
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected string
+	}{
+		{"1.2.3", "1.2.3"},
+		{"v1.2.3", "1.2.3"},
+		{"V1.2.3", "1.2.3"},
+		{" v1.2.3\n", "1.2.3"},
+		{"1.2", "1.2.0"},
+		{"1", "1.0.0"},
+		{"v2", "2.0.0"},
+		{"1.2-rc.1", "1.2.0-rc.1"},
+		{"1+build.5", "1.0.0+build.5"},
+		{"v1.2-beta.2+exp.sha.5114f85", "1.2.0-beta.2+exp.sha.5114f85"},
+		{"1.2.3-alpha-1", "1.2.3-alpha-1"},
+		{"0.0.0", "0.0.0"},
+	}
+	for _, testCase := range testCases {
+		result, err := Canonicalize(testCase.version)
+		if err != nil {
+			t.Fatalf("failed to canonicalize %q: %v", testCase.version, err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %q for %q but got %q", testCase.expected, testCase.version, result)
+		}
+	}
+
+	for _, version := range []string{"", "v", "1.2.3.4", "1..2", "01.2.3", "1.2.x", "1.2.3-", "1.2.3-rc..1", "1.2.3+", "latest", "1.2 .3"} {
+		if result, err := Canonicalize(version); err == nil {
+			t.Fatalf("expected an error for %q but got %q", version, result)
+		}
+	}
+}
+
+func TestValidateBump(t *testing.T) {
+	var testCases = []struct {
+		current string
+		target  string
+		valid   bool
+	}{
+		{"1.2.3", "2.0.0", true},
+		{"1.2.3", "1.3.0", true},
+		{"v1.2.3", "v1.2.4", true},
+		{"1.2.3", "1.3.0-rc.1", true},
+		{"1.2.3", "2.0.0-alpha", true},
+		{"1.2.3", "1.2.4+build.5", true},
+		{"1.3.0-rc.1", "1.3.0-rc.2", true},
+		{"1.3.0-rc.1", "1.3.0", true},
+		{"1.3.0-rc.1", "1.3.0+build.5", true},
+		{"2.0.0-alpha", "2.0.0-beta.1", true},
+		// skips
+		{"1.2.3", "3.0.0", false},
+		{"1.2.3", "1.4.0", false},
+		{"1.2.3", "1.2.5", false},
+		{"1.2.3", "2.1.0", false},
+		{"1.2.3", "1.3.1", false},
+		// skipped releases of prereleases
+		{"1.3.0-rc.1", "1.3.1", false},
+		{"1.3.0-rc.1", "1.4.0", false},
+		{"1.3.0-rc.1", "2.0.0", false},
+		{"1.3.0-rc.1", "2.0.0-rc.1", false},
+		// downgrades
+		{"1.2.3", "1.2.3", false},
+		{"1.2.3", "1.2.2", false},
+		{"1.2.3", "1.2.3-rc.1", false},
+		{"1.3.0-rc.2", "1.3.0-rc.1", false},
+	}
+	for _, testCase := range testCases {
+		err := ValidateBump(testCase.current, testCase.target)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("expected bump from %v to %v to be valid: %v but got %v", testCase.current, testCase.target, testCase.valid, err)
+		}
+	}
+
+	if err := ValidateBump("1.2", "1.3.0"); err == nil {
+		t.Fatal("expected an error for an invalid version")
+	}
+}
+
+func TestNightlyVersion(t *testing.T) {
+	date := time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC)
+	var testCases = []struct {
+		base     string
+		date     time.Time
+		count    int
+		expected string
+	}{
+		{"1.3", date, 42, "1.3.0-nightly.20240601.42"},
+		{"v2.0", date, 0, "2.0.0-nightly.20240601.0"},
+		{"1.3", date.In(time.FixedZone("CEST", 2*60*60)), 7, "1.3.0-nightly.20240601.7"},
+	}
+	for _, testCase := range testCases {
+		nightly, err := NightlyVersion(testCase.base, testCase.date, testCase.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if nightly != testCase.expected {
+			t.Fatalf("expected %v but got %v", testCase.expected, nightly)
+		}
+		if _, err = ParseSemver(nightly); err != nil {
+			t.Fatalf("expected a valid semantic version but got %v", err)
+		}
+	}
+
+	for _, base := range []string{"1", "1.3.0", "one.two", ""} {
+		if _, err := NightlyVersion(base, date, 1); err == nil {
+			t.Fatalf("expected an error for release line %q", base)
+		}
+	}
+	if _, err := NightlyVersion("1.3", date, -1); err == nil {
+		t.Fatal("expected an error for a negative commit count")
+	}
+}
+
+func TestSanitizeVersion(t *testing.T) {
+	var testCases = []struct {
+		version  string
+		expected string
+		valid    bool
+	}{
+		{"1.2.3+2032d5b-dirty", "1.2.3+2032d5b-dirty", true},
+		{" v1.2.3\n", "v1.2.3", true},
+		{"", "", true},
+		{"1.2.3\x1b[31m", "", false},
+		{"1.2\x00.3", "", false},
+		{"1.2.3\x7f", "", false},
+		{"1.2.3-ünicode", "", false},
+		{"1.2.3-​", "", false},
+	}
+	for _, testCase := range testCases {
+		result, err := SanitizeVersion(testCase.version)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("expected %q to be valid: %v but got %v", testCase.version, testCase.valid, err)
+		}
+		if result != testCase.expected {
+			t.Fatalf("expected %q but got %q", testCase.expected, result)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// Package main is a trivial plugin used to test version injection in -buildmode=plugin builds.
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/gravitational/version"
+)
+
+// Info returns the JSON-formatted version information the plugin was built with.
+func Info() string {
+	payload, err := json.Marshal(version.Get())
+	if err != nil {
+		panic(err)
+	}
+	return string(payload)
+}
+
+// main is never invoked for plugins but lets the package build with the default build mode.
+func main() {}
@@ -0,0 +1,31 @@
+// Package main is a host program that loads the plugin given on the command line
+// and prints its version information.
+// It deliberately does not import the version package so that the plugin
+// uses its own copy with the values injected when the plugin was built.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"plugin"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalf("usage: %s path/to/plugin.so", os.Args[0])
+	}
+	p, err := plugin.Open(os.Args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	symbol, err := p.Lookup("Info")
+	if err != nil {
+		log.Fatal(err)
+	}
+	info, ok := symbol.(func() string)
+	if !ok {
+		log.Fatalf("unexpected type of Info: %T", symbol)
+	}
+	fmt.Print(info())
+}
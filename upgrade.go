@@ -0,0 +1,43 @@
+/*
+Copyright 2015 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package version
+
+import (
+	"os"
+	"strings"
+)
+
+// DetectUpgrade determines if the running application has been upgraded since it last ran
+// by comparing the version stored in the state file at statePath to the current version.
+// The state file is updated with the current version.
+//
+// It returns the previously stored version and whether it differs from the current version.
+// A missing state file denotes a fresh install: previous is empty and upgraded is false.
+func DetectUpgrade(statePath string) (previous string, upgraded bool, err error) {
+	current := Get().Version
+	contents, err := os.ReadFile(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	previous = strings.TrimSpace(string(contents))
+	if previous == current && err == nil {
+		return previous, false, nil
+	}
+	if err := os.WriteFile(statePath, []byte(current+"\n"), 0644); err != nil {
+		return previous, false, err
+	}
+	return previous, previous != "", nil
+}
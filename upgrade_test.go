@@ -0,0 +1,42 @@
+package version
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectUpgrade(t *testing.T) {
+	defer saveVars()()
+	statePath := filepath.Join(t.TempDir(), "version")
+
+	var testCases = []struct {
+		comment  string
+		version  string
+		previous string
+		upgraded bool
+	}{
+		{comment: "fresh install", version: "1.2.0", previous: "", upgraded: false},
+		{comment: "same version", version: "1.2.0", previous: "1.2.0", upgraded: false},
+		{comment: "upgraded", version: "1.3.0", previous: "1.2.0", upgraded: true},
+		{comment: "after upgrade", version: "1.3.0", previous: "1.3.0", upgraded: false},
+	}
+	for _, testCase := range testCases {
+		Set(Info{Version: testCase.version})
+		previous, upgraded, err := DetectUpgrade(statePath)
+		if err != nil {
+			t.Fatalf("%v: %v", testCase.comment, err)
+		}
+		if previous != testCase.previous || upgraded != testCase.upgraded {
+			t.Fatalf("%v: expected %q, %v but got %q, %v", testCase.comment,
+				testCase.previous, testCase.upgraded, previous, upgraded)
+		}
+		contents, err := os.ReadFile(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != testCase.version+"\n" {
+			t.Fatalf("%v: expected state file to contain %q but got %q", testCase.comment, testCase.version, contents)
+		}
+	}
+}
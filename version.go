@@ -16,8 +16,16 @@ limitations under the License.
 package version
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Info describes build version with a semver-complaint version string and
@@ -26,21 +34,272 @@ type Info struct {
 	Version      string `json:"version"`
 	GitCommit    string `json:"gitCommit"`
 	GitTreeState string `json:"gitTreeState"`
+	GitBranch    string `json:"gitBranch,omitempty"`
+	GoOS         string `json:"goOS,omitempty"`
+	GoArch       string `json:"goArch,omitempty"`
+	// BuildPlatform is the target platform if it has been injected as a single os/arch value
+	BuildPlatform string `json:"platform,omitempty"`
+	// SourceTreeHash is the hash of the git tree object of the build.
+	// Unlike the commit ID, it only depends on the contents of the source tree.
+	SourceTreeHash string `json:"sourceTreeHash,omitempty"`
+	// CommitTime is the time of the commit in RFC 3339 format.
+	CommitTime string `json:"commitTime,omitempty"`
+	// BuildTime is the time of the build in RFC 3339 format.
+	BuildTime string `json:"buildTime,omitempty"`
+	// BuildTimeSource describes where the build time has been obtained from:
+	// either BuildTimeSourceEpoch or BuildTimeSourceClock.
+	BuildTimeSource string `json:"buildTimeSource,omitempty"`
+	BuildUser       string `json:"buildUser,omitempty"`
+	BuildHost       string `json:"buildHost,omitempty"`
+	// GoVersion is the version of the Go runtime the program has been built with.
+	GoVersion string `json:"goVersion,omitempty"`
+	// Submodules lists the git submodules of the build.
+	Submodules []SubmoduleInfo `json:"submodules,omitempty"`
+	// Dirty is true if the git tree had changes at the time of the build.
+	Dirty bool `json:"dirty,omitempty"`
+	// Broken is true if git reported the repository as broken.
+	// It is only set by FromDescribe: linkflags does not inject it,
+	// hence it is always false for the information returned by Get.
+	Broken bool `json:"broken,omitempty"`
+	// Edition is the edition of the product, one of Editions.
+	Edition string `json:"edition,omitempty"`
+	// ModulePath is the path of the main Go module of the program.
+	ModulePath string `json:"modulePath,omitempty"`
+	// Attrs are custom build attributes set with `linkflags -attr key=value`.
+	Attrs map[string]string `json:"attrs,omitempty"`
+	// Individual components of the version, only set with `linkflags -split-version`
+	VersionMajor string `json:"versionMajor,omitempty"`
+	VersionMinor string `json:"versionMinor,omitempty"`
+	VersionPatch string `json:"versionPatch,omitempty"`
+}
+
+// SubmoduleInfo describes a git submodule.
+type SubmoduleInfo struct {
+	Path   string `json:"path"`
+	Commit string `json:"commit"`
 }
 
 // Get returns current build version.
 func Get() Info {
-	return Info{
-		Version:      version,
-		GitCommit:    gitCommit,
-		GitTreeState: gitTreeState,
+	info := Info{
+		Version:         decodeValue(version),
+		GitCommit:       decodeValue(gitCommit),
+		GitTreeState:    decodeValue(gitTreeState),
+		GitBranch:       decodeValue(gitBranch),
+		GoOS:            decodeValue(goOS),
+		GoArch:          decodeValue(goArch),
+		BuildPlatform:   decodeValue(platform),
+		SourceTreeHash:  decodeValue(sourceTreeHash),
+		CommitTime:      decodeValue(commitTime),
+		BuildTime:       decodeValue(buildTime),
+		BuildTimeSource: decodeValue(buildTimeSource),
+		BuildUser:       decodeValue(buildUser),
+		BuildHost:       decodeValue(buildHost),
+		GoVersion:       runtime.Version(),
+		Submodules:      decodeSubmodules(decodeValue(gitSubmodules)),
+		Dirty:           treeDirty(decodeValue(gitTreeDirty), decodeValue(gitTreeState)),
+		Edition:         decodeValue(edition),
+		ModulePath:      mainModulePath(decodeValue(modulePath)),
+		Attrs:           decodeAttrs(decodeValue(buildAttrs)),
+		VersionMajor:    decodeValue(versionMajor),
+		VersionMinor:    decodeValue(versionMinor),
+		VersionPatch:    decodeValue(versionPatch),
 	}
+	if gitCommit == defaultGitCommit {
+		info = legacyInfo(info)
+	}
+	return info
 }
 
 func (r Info) String() string {
 	return r.Version
 }
 
+// IsDefaultBranch determines if the build has been made from defaultBranch.
+// Builds from a detached HEAD are never considered to be from the default branch.
+func (r Info) IsDefaultBranch(defaultBranch string) bool {
+	return r.GitBranch != "" && r.GitBranch == defaultBranch
+}
+
+// IsDevBuild determines if the running program is a development build:
+// either built without version information, from a dirty git tree or
+// from a commit past the most recent tag.
+// It is meant to disable telemetry, update checks and the like for local builds.
+// Builds with `linkflags -minimal` carry no commit ID and are always development builds.
+func IsDevBuild() bool {
+	info := Get()
+	if info.Version == "" || info.GitCommit == defaultGitCommit || info.GitTreeState == treeStateDirty {
+		return true
+	}
+	parsed, err := ParseSemver(info.Version)
+	if err != nil {
+		return true
+	}
+	// versions of commits past a tag carry the commit ID as metadata
+	return parsed.Metadata != ""
+}
+
+// Platform returns the target platform of the build in os/arch format, e.g. `linux/amd64`.
+// It returns an empty string if the platform is unknown.
+func (r Info) Platform() string {
+	if r.BuildPlatform != "" {
+		return r.BuildPlatform
+	}
+	if r.GoOS == "" || r.GoArch == "" {
+		return ""
+	}
+	return r.GoOS + "/" + r.GoArch
+}
+
+// CommitTimeParsed returns the time of the commit the build has been made from.
+// Unlike the time of the build, it only depends on the contents of the build.
+func (r Info) CommitTimeParsed() (time.Time, error) {
+	if r.CommitTime == "" {
+		return time.Time{}, fmt.Errorf("commit time is not available")
+	}
+	return time.Parse(time.RFC3339, r.CommitTime)
+}
+
+// Sources of the build time
+const (
+	// BuildTimeSourceEpoch denotes a build time obtained from the SOURCE_DATE_EPOCH
+	// environment variable as defined by https://reproducible-builds.org/specs/source-date-epoch/
+	BuildTimeSourceEpoch = "SOURCE_DATE_EPOCH"
+	// BuildTimeSourceClock denotes a build time obtained from the wall clock
+	BuildTimeSourceClock = "clock"
+)
+
+// IsReproducible determines if the version information is free of volatile build metadata
+// that differs between otherwise identical builds: the user and host of the build
+// and a build time that has not been derived from SOURCE_DATE_EPOCH.
+func (r Info) IsReproducible() bool {
+	if r.BuildUser != "" || r.BuildHost != "" {
+		return false
+	}
+	return r.BuildTime == "" || r.BuildTimeSource == BuildTimeSourceEpoch
+}
+
+// Seed returns a stable seed derived from the build for deterministic bucketing,
+// e.g. to enable experimental features for a subset of builds.
+// The seed is computed from GitCommit or from Version if the commit is unknown.
+func (r Info) Seed() uint64 {
+	key := r.GitCommit
+	if key == "" || key == defaultGitCommit {
+		key = r.Version
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	return hash.Sum64()
+}
+
+// placeholder determines if value is the default of a variable that has not been set
+// with the linker flags: the defaults of the version and the commit contain
+// `$Format:` placeholders that only `git archive` expands.
+func placeholder(value string) bool {
+	return strings.Contains(value, "$Format:")
+}
+
+// treeDirty determines if the git tree was dirty from the values of the variables
+// gitTreeDirty and, if not set, gitTreeState.
+func treeDirty(dirty, treeState string) bool {
+	if parsed, err := strconv.ParseBool(dirty); err == nil {
+		return parsed
+	}
+	return treeState == treeStateDirty
+}
+
+// readBuildInfo returns the build information embedded in the running binary.
+// It is a variable to be replaced in tests.
+var readBuildInfo = debug.ReadBuildInfo
+
+// buildInfoModulePath is the path of the main module from the build information.
+// It is read once as the build information does not change while the program runs.
+var buildInfoModulePath string
+
+// buildInfoModulePathOnce guards buildInfoModulePath and is reset in tests replacing readBuildInfo.
+var buildInfoModulePathOnce sync.Once
+
+// mainModulePath returns the path of the main module: injected, if set,
+// or obtained from the build information embedded in the binary otherwise.
+func mainModulePath(injected string) string {
+	if injected != "" {
+		return injected
+	}
+	buildInfoModulePathOnce.Do(func() {
+		if buildInfo, ok := readBuildInfo(); ok {
+			buildInfoModulePath = buildInfo.Main.Path
+		}
+	})
+	return buildInfoModulePath
+}
+
+// encodedValuePrefix marks base64-encoded values of variables
+// set with `linkflags -encode=base64`
+const encodedValuePrefix = "b64:"
+
+// decodeValue returns the value of a variable set by the linker
+// decoding it if it has been base64-encoded.
+func decodeValue(value string) string {
+	if !strings.HasPrefix(value, encodedValuePrefix) {
+		return value
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encodedValuePrefix))
+	if err != nil {
+		return value
+	}
+	return string(decoded)
+}
+
+// encodeSubmodules encodes submodules for use as a linker flag value.
+func encodeSubmodules(submodules []SubmoduleInfo) string {
+	if len(submodules) == 0 {
+		return ""
+	}
+	payload, err := json.Marshal(submodules)
+	if err != nil {
+		panic(err)
+	}
+	return string(payload)
+}
+
+// decodeSubmodules decodes the submodules from the linker flag value.
+// Malformed values are ignored.
+func decodeSubmodules(value string) []SubmoduleInfo {
+	if value == "" {
+		return nil
+	}
+	var submodules []SubmoduleInfo
+	if err := json.Unmarshal([]byte(value), &submodules); err != nil {
+		return nil
+	}
+	return submodules
+}
+
+// encodeAttrs encodes build attributes for use as a linker flag value.
+func encodeAttrs(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	payload, err := json.Marshal(attrs)
+	if err != nil {
+		panic(err)
+	}
+	return string(payload)
+}
+
+// decodeAttrs decodes the build attributes from the linker flag value.
+// Malformed values are ignored.
+func decodeAttrs(value string) map[string]string {
+	if value == "" {
+		return nil
+	}
+	var attrs map[string]string
+	if err := json.Unmarshal([]byte(value), &attrs); err != nil {
+		return nil
+	}
+	return attrs
+}
+
 // Print prints build version in default format.
 func Print() {
 	payload, err := json.Marshal(Get())
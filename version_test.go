@@ -1,11 +1,16 @@
 package version
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gravitational/version/pkg/tool"
 )
@@ -14,6 +19,41 @@ const testPackage = "github.com/gravitational/version/test"
 
 const testBinary = "test"
 
+const (
+	testPluginPackage     = "github.com/gravitational/version/test/plugin"
+	testPluginHostPackage = "github.com/gravitational/version/test/pluginhost"
+)
+
+// linkerVars lists the package variables set by the linker flags.
+var linkerVars = []*string{
+	&version, &gitCommit, &gitTreeState, &gitTreeDirty, &gitBranch, &goOS, &goArch, &platform,
+	&sourceTreeHash, &commitTime, &buildTime, &buildTimeSource, &buildUser, &buildHost,
+	&gitSubmodules, &edition, &modulePath, &buildAttrs, &versionMajor, &versionMinor, &versionPatch,
+}
+
+// saveVars saves the raw values of the variables set by the linker flags
+// and returns a function that restores them, e.g.:
+//
+//	defer saveVars()()
+func saveVars() func() {
+	values := make([]string, len(linkerVars))
+	for i, value := range linkerVars {
+		values[i] = *value
+	}
+	return func() {
+		for i, value := range linkerVars {
+			*value = values[i]
+		}
+	}
+}
+
+// resetVars resets the variables set by the linker flags to their defaults
+// as in a build without linker flags, e.g.:
+//
+//	defer saveVars()()
+//	resetVars()
+var resetVars = saveVars()
+
 func TestAutoBuildVersion(t *testing.T) {
 	if _, err := exec.LookPath("linkflags"); err != nil {
 		t.Skip("skipping because linkflags binary not found")
@@ -78,6 +118,80 @@ func TestAutoBuildVersion(t *testing.T) {
 	}
 }
 
+// TestPluginBuildVersion verifies that the linker flags work unchanged for -buildmode=plugin.
+func TestPluginBuildVersion(t *testing.T) {
+	if _, err := exec.LookPath("linkflags"); err != nil {
+		t.Skip("skipping because linkflags binary not found")
+	}
+	goTool := newGoTool()
+	if cgo, err := goTool.Exec("env", "CGO_ENABLED"); err != nil || cgo != "1" {
+		t.Skip("skipping because plugins require cgo")
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pluginPath := filepath.Join(dir, "test", "plugin")
+
+	git := newGit(pluginPath)
+	_, err = git.RawExec("init", pluginPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err = os.RemoveAll(filepath.Join(pluginPath, ".git")); err != nil {
+			t.Fatalf("failed to clean up test repository: %v", err)
+		}
+	}()
+
+	_, err = git.Exec("--work-tree", pluginPath, "add", filepath.Join(pluginPath, "main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = git.Exec("commit", "-m", "Initial commit")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitID, err := git.Exec("rev-parse", "HEAD^{commit}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linkFlags, err := exec.Command("linkflags", "-pkg", pluginPath).CombinedOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	pluginBinary := filepath.Join(outDir, "plugin.so")
+	hostBinary := filepath.Join(outDir, "host")
+	_, err = goTool.Exec("build", "-buildmode=plugin", "-o", pluginBinary, "-ldflags", string(linkFlags), testPluginPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = goTool.Exec("build", "-o", hostBinary, testPluginHostPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := exec.Command(hostBinary, pluginBinary).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to load plugin: %v (%s)", err, payload)
+	}
+
+	var info Info
+	if err = json.Unmarshal(payload, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	if info.GitCommit != commitID {
+		t.Fatalf("expected git commit `%s` but got `%s`", commitID, info.GitCommit)
+	}
+}
+
 func newGit(pkg string) *tool.T {
 	args := []string{"--git-dir", filepath.Join(pkg, ".git")}
 	return &tool.T{Cmd: "git", Args: args}
@@ -86,3 +200,267 @@ func newGit(pkg string) *tool.T {
 func newGoTool() *tool.T {
 	return &tool.T{Cmd: "go"}
 }
+
+func TestIsDefaultBranch(t *testing.T) {
+	var testCases = []struct {
+		branch   string
+		expected bool
+	}{
+		{"master", true},
+		{"feature/foo", false},
+		{"", false},
+	}
+	for _, testCase := range testCases {
+		info := Info{GitBranch: testCase.branch}
+		if result := info.IsDefaultBranch("master"); result != testCase.expected {
+			t.Fatalf("expected %v for branch %q but got %v", testCase.expected, testCase.branch, result)
+		}
+	}
+}
+
+func TestPlatform(t *testing.T) {
+	var testCases = []struct {
+		info     Info
+		expected string
+	}{
+		{Info{GoOS: "linux", GoArch: "amd64"}, "linux/amd64"},
+		{Info{BuildPlatform: "darwin/arm64"}, "darwin/arm64"},
+		{Info{GoOS: "linux", GoArch: "amd64", BuildPlatform: "darwin/arm64"}, "darwin/arm64"},
+		{Info{GoOS: "linux"}, ""},
+		{Info{}, ""},
+	}
+	for _, testCase := range testCases {
+		if result := testCase.info.Platform(); result != testCase.expected {
+			t.Fatalf("expected platform %q for %+v but got %q", testCase.expected, testCase.info, result)
+		}
+	}
+}
+
+func TestGetPlatform(t *testing.T) {
+	defer saveVars()()
+
+	Set(Info{GoOS: "linux", GoArch: "arm"})
+	if platform := Get().Platform(); platform != "linux/arm" {
+		t.Fatalf("expected platform linux/arm but got %q", platform)
+	}
+	Set(Info{BuildPlatform: "windows/386"})
+	if platform := Get().Platform(); platform != "windows/386" {
+		t.Fatalf("expected platform windows/386 but got %q", platform)
+	}
+}
+
+func TestCommitTimeParsed(t *testing.T) {
+	info := Info{CommitTime: "2024-06-01T12:30:00+02:00"}
+	commitTime, err := info.CommitTimeParsed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2024, time.June, 1, 10, 30, 0, 0, time.UTC)
+	if !commitTime.Equal(expected) {
+		t.Fatalf("expected commit time %v but got %v", expected, commitTime)
+	}
+
+	if _, err = (Info{}).CommitTimeParsed(); err == nil {
+		t.Fatal("expected an error for missing commit time")
+	}
+	if _, err = (Info{CommitTime: "yesterday"}).CommitTimeParsed(); err == nil {
+		t.Fatal("expected an error for invalid commit time")
+	}
+}
+
+func TestIsReproducible(t *testing.T) {
+	var testCases = []struct {
+		info     Info
+		expected bool
+	}{
+		{Info{Version: "1.2.3", GitCommit: "2032d5b"}, true},
+		{Info{Version: "1.2.3", BuildTime: "2024-06-01T10:30:00Z", BuildTimeSource: BuildTimeSourceEpoch}, true},
+		{Info{Version: "1.2.3", BuildTime: "2024-06-01T10:30:00Z", BuildTimeSource: BuildTimeSourceClock}, false},
+		{Info{Version: "1.2.3", BuildTime: "2024-06-01T10:30:00Z"}, false},
+		{Info{Version: "1.2.3", BuildUser: "builder"}, false},
+		{Info{Version: "1.2.3", BuildHost: "ci-1"}, false},
+		{Info{BuildTime: "2024-06-01T10:30:00Z", BuildTimeSource: BuildTimeSourceEpoch, BuildHost: "ci-1"}, false},
+	}
+	for _, testCase := range testCases {
+		if result := testCase.info.IsReproducible(); result != testCase.expected {
+			t.Fatalf("expected %v for %+v but got %v", testCase.expected, testCase.info, result)
+		}
+	}
+}
+
+func TestIsDevBuild(t *testing.T) {
+	defer saveVars()()
+
+	var testCases = []struct {
+		info     Info
+		expected bool
+	}{
+		{Info{Version: "v1.2.0", GitCommit: "2032d5b", GitTreeState: "clean"}, false},
+		{Info{Version: "1.2.0-rc.1", GitCommit: "2032d5b", GitTreeState: "clean"}, false},
+		{Info{Version: "v1.2.0-dirty", GitCommit: "2032d5b", GitTreeState: "dirty"}, true},
+		{Info{Version: "1.2.3+2032d5b1a2b3c4", GitCommit: "2032d5b", GitTreeState: "clean"}, true},
+		{Info{Version: "", GitCommit: "2032d5b", GitTreeState: "clean"}, true},
+		{Info{Version: "v0.0.0-master+$Format:%h$", GitCommit: defaultGitCommit, GitTreeState: "not a git tree"}, true},
+		// builds with -minimal only carry the version
+		{Info{Version: "v0.0.1", GitCommit: defaultGitCommit, GitTreeState: "not a git tree"}, true},
+	}
+	for _, testCase := range testCases {
+		Set(testCase.info)
+		if result := IsDevBuild(); result != testCase.expected {
+			t.Fatalf("expected %v for %+v but got %v", testCase.expected, testCase.info, result)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	info := Info{Version: "1.2.3", GitCommit: "2032d5b"}
+	if info.Seed() != info.Seed() {
+		t.Fatalf("expected seed for %+v to be stable", info)
+	}
+	other := Info{Version: "1.2.3", GitCommit: "a1b2c3d"}
+	if info.Seed() == other.Seed() {
+		t.Fatalf("expected different seeds for commits %v and %v", info.GitCommit, other.GitCommit)
+	}
+	fallback := Info{Version: "1.2.3"}
+	if fallback.Seed() != (Info{Version: "1.2.3", GitCommit: defaultGitCommit}).Seed() {
+		t.Fatalf("expected seed to fall back to version if the commit is unknown")
+	}
+	if fallback.Seed() == (Info{Version: "1.2.4"}).Seed() {
+		t.Fatalf("expected different seeds for different versions")
+	}
+}
+
+func TestSubmodules(t *testing.T) {
+	defer saveVars()()
+
+	submodules := []SubmoduleInfo{{Path: "vendor/lib", Commit: "3f2a1b9"}}
+	Set(Info{Submodules: submodules})
+	if info := Get(); !reflect.DeepEqual(info.Submodules, submodules) {
+		t.Fatalf("expected submodules %+v but got %+v", submodules, info.Submodules)
+	}
+
+	gitSubmodules = "malformed"
+	if info := Get(); info.Submodules != nil {
+		t.Fatalf("expected malformed submodules to be ignored but got %+v", info.Submodules)
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	defer saveVars()()
+
+	attrs := map[string]string{"tracking-id": "42", "jira_ticket": "OPS-1234"}
+	Set(Info{Attrs: attrs})
+	if info := Get(); !reflect.DeepEqual(info.Attrs, attrs) {
+		t.Fatalf("expected attributes %v but got %v", attrs, info.Attrs)
+	}
+
+	buildAttrs = "malformed"
+	if info := Get(); info.Attrs != nil {
+		t.Fatalf("expected malformed attributes to be ignored but got %v", info.Attrs)
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	defer saveVars()()
+
+	values := []string{"feature/new ui", `John "Doe" $USER`, "line\nbreak", "b64:literal"}
+	for _, value := range values {
+		Set(Info{GitBranch: encodedValuePrefix + base64.StdEncoding.EncodeToString([]byte(value))})
+		if branch := Get().GitBranch; branch != value {
+			t.Fatalf("expected %q but got %q", value, branch)
+		}
+	}
+
+	Set(Info{GitBranch: "b64:not base64!"})
+	if branch := Get().GitBranch; branch != "b64:not base64!" {
+		t.Fatalf("expected an invalid encoded value to be returned verbatim but got %q", branch)
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	defer saveVars()()
+	defer replaceBuildInfo(func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Main: debug.Module{Path: "github.com/example/app"}}, true
+	})()
+	Set(Info{})
+	if path := Get().ModulePath; path != "github.com/example/app" {
+		t.Fatalf("expected the module path from the build information but got %q", path)
+	}
+
+	Set(Info{ModulePath: "github.com/fork/app"})
+	if path := Get().ModulePath; path != "github.com/fork/app" {
+		t.Fatalf("expected the injected module path but got %q", path)
+	}
+
+	replaceBuildInfo(func() (*debug.BuildInfo, bool) { return nil, false })
+	Set(Info{})
+	if path := Get().ModulePath; path != "" {
+		t.Fatalf("expected no module path without build information but got %q", path)
+	}
+}
+
+func TestModulePathReadOnce(t *testing.T) {
+	defer saveVars()()
+	var reads int
+	defer replaceBuildInfo(func() (*debug.BuildInfo, bool) {
+		reads++
+		return &debug.BuildInfo{Main: debug.Module{Path: "github.com/example/app"}}, true
+	})()
+
+	Set(Info{})
+	for i := 0; i < 3; i++ {
+		Get()
+	}
+	if reads != 1 {
+		t.Fatalf("expected the build information to be read once but got %v reads", reads)
+	}
+}
+
+// replaceBuildInfo replaces the build information of the running binary with the result
+// of read and returns a function that restores the original build information.
+func replaceBuildInfo(read func() (*debug.BuildInfo, bool)) func() {
+	saved := readBuildInfo
+	readBuildInfo, buildInfoModulePath, buildInfoModulePathOnce = read, "", sync.Once{}
+	return func() {
+		readBuildInfo, buildInfoModulePath, buildInfoModulePathOnce = saved, "", sync.Once{}
+	}
+}
+
+func TestMinimal(t *testing.T) {
+	defer saveVars()()
+
+	Set(Info{Version: "1.2.0"})
+	if result := Minimal(); result != "1.2.0" {
+		t.Fatalf("expected version 1.2.0 but got %q", result)
+	}
+}
+
+func TestTreeDirty(t *testing.T) {
+	defer saveVars()()
+	defer func(value string) { gitTreeDirty = value }(gitTreeDirty)
+
+	var testCases = []struct {
+		treeState string
+		treeDirty string
+		expected  bool
+	}{
+		{treeState: "clean", treeDirty: "false", expected: false},
+		{treeState: "dirty", treeDirty: "true", expected: true},
+		{treeState: "dirty", treeDirty: "", expected: true},
+		{treeState: "clean", treeDirty: "", expected: false},
+		{treeState: "not a git tree", treeDirty: "invalid", expected: false},
+	}
+	for _, testCase := range testCases {
+		Set(Info{GitTreeState: testCase.treeState})
+		gitTreeDirty = testCase.treeDirty
+		if info := Get(); info.Dirty != testCase.expected || info.GitTreeState != testCase.treeState {
+			t.Fatalf("expected tree state %v and dirty %v for %q but got %v and %v", testCase.treeState,
+				testCase.expected, testCase.treeDirty, info.GitTreeState, info.Dirty)
+		}
+	}
+
+	Set(Info{GitTreeState: "dirty", Dirty: true})
+	if info := Get(); !info.Dirty {
+		t.Fatalf("expected Set to preserve Dirty but got %+v", info)
+	}
+}